@@ -0,0 +1,57 @@
+// Command bookshelf-import walks a directory of ebooks and sidecar
+// metadata and imports them into the library via LibraryService.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+
+	"github.com/haleyrc/bookshelf/library/events"
+	importer "github.com/haleyrc/bookshelf/library/import"
+	"github.com/haleyrc/bookshelf/library/service"
+	"github.com/haleyrc/bookshelf/library/store"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	if len(os.Args) != 2 {
+		return fmt.Errorf("usage: bookshelf-import <directory>")
+	}
+	dir := os.Args[1]
+
+	godotenv.Load()
+
+	url := os.Getenv("DATABASE_URL")
+	if url == "" {
+		return fmt.Errorf("set the DATABASE_URL environment variable")
+	}
+	db := sqlx.MustConnect("postgres", url)
+	defer db.Close()
+
+	ls := &store.LibraryStore{DB: db, Events: &events.EventStore{DB: db}}
+	svc := &service.LibraryService{Store: ls}
+	imp := &importer.Importer{Service: svc, Files: ls}
+
+	report, err := imp.Import(context.Background(), os.DirFS(dir))
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	fmt.Printf("imported %d, skipped %d, failed %d\n", report.Imported, report.Skipped, len(report.Failed))
+	for _, path := range report.Failed {
+		fmt.Printf("  failed: %s\n", path)
+	}
+
+	return nil
+}
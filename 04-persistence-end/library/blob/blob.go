@@ -0,0 +1,26 @@
+// Package blob stores and retrieves the raw bytes of book files and
+// covers, independent of where LibraryStore keeps the rows that describe
+// them.
+package blob
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// BlobStore puts, gets, and deletes objects by key. Put returns the
+// object's URL so callers can record where it ended up.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (string, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, string, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// URLSigner is implemented by BlobStores that can mint a temporary, direct
+// download URL for an object without proxying the bytes through the
+// application. LibraryService uses this, when present, to hydrate
+// BookFile.DownloadURL.
+type URLSigner interface {
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
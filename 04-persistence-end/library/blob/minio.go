@@ -0,0 +1,57 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// MinIOStore implements BlobStore against a MinIO or S3-compatible bucket.
+type MinIOStore struct {
+	Client *minio.Client
+	Bucket string
+}
+
+func (s *MinIOStore) Put(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	_, err := s.Client.PutObject(ctx, s.Bucket, key, r, -1, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return "", fmt.Errorf("put %s: %w", key, err)
+	}
+
+	return s.Client.EndpointURL().String() + "/" + s.Bucket + "/" + key, nil
+}
+
+func (s *MinIOStore) Get(ctx context.Context, key string) (io.ReadCloser, string, error) {
+	obj, err := s.Client.GetObject(ctx, s.Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, "", fmt.Errorf("get %s: %w", key, err)
+	}
+
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, "", fmt.Errorf("get %s: %w", key, err)
+	}
+
+	return obj, info.ContentType, nil
+}
+
+func (s *MinIOStore) Delete(ctx context.Context, key string) error {
+	if err := s.Client.RemoveObject(ctx, s.Bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// SignedURL satisfies URLSigner so LibraryService can hand back direct,
+// time-limited download links instead of proxying object bytes itself.
+func (s *MinIOStore) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	u, err := s.Client.PresignedGetObject(ctx, s.Bucket, key, ttl, nil)
+	if err != nil {
+		return "", fmt.Errorf("signed url %s: %w", key, err)
+	}
+	return u.String(), nil
+}
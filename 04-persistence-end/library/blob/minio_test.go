@@ -0,0 +1,122 @@
+package blob_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	tcminio "github.com/testcontainers/testcontainers-go/modules/minio"
+
+	"github.com/haleyrc/bookshelf/library/blob"
+)
+
+var bs blob.MinIOStore
+
+// TestMain starts a throwaway MinIO container via testcontainers-go for the
+// package's tests to share, the same way store_test.go shares one Postgres
+// connection, and tears it down once they've all run.
+//
+// Like store_test.go's TEST_DATABASE_URL, this suite is opt-in: it only
+// runs when TEST_MINIO_DOCKER is set, and exits 0 with a message instead of
+// failing when it's not, or when Docker turns out not to be reachable.
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+
+	path := filepath.Join("..", "..", ".env")
+	godotenv.Load(path)
+
+	if os.Getenv("TEST_MINIO_DOCKER") == "" {
+		fmt.Println("set the TEST_MINIO_DOCKER environment variable to run this test suite")
+		os.Exit(0)
+	}
+
+	container, err := tcminio.RunContainer(ctx)
+	if err != nil {
+		fmt.Println("start minio container:", err)
+		os.Exit(0)
+	}
+	defer container.Terminate(ctx)
+
+	endpoint, err := container.ConnectionString(ctx)
+	if err != nil {
+		fmt.Println("get minio connection string:", err)
+		os.Exit(1)
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds: credentials.NewStaticV4(container.Username, container.Password, ""),
+	})
+	if err != nil {
+		fmt.Println("connect to minio:", err)
+		os.Exit(1)
+	}
+
+	if err := client.MakeBucket(ctx, "bookshelf-test", minio.MakeBucketOptions{}); err != nil {
+		fmt.Println("make bucket:", err)
+		os.Exit(1)
+	}
+
+	bs = blob.MinIOStore{Client: client, Bucket: "bookshelf-test"}
+	os.Exit(m.Run())
+}
+
+func TestMinIOStore_PutGetDelete(t *testing.T) {
+	ctx := context.Background()
+
+	key := "book-files/put-get-delete-test.txt"
+	if _, err := bs.Put(ctx, key, bytes.NewBufferString("hello, bookshelf"), "text/plain"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	defer bs.Delete(ctx, key)
+
+	r, contentType, err := bs.Get(ctx, key)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	defer r.Close()
+
+	if contentType != "text/plain" {
+		t.Errorf("expected content type %q, but got %q", "text/plain", contentType)
+	}
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if string(got) != "hello, bookshelf" {
+		t.Errorf("expected %q, but got %q", "hello, bookshelf", string(got))
+	}
+
+	if err := bs.Delete(ctx, key); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if _, _, err := bs.Get(ctx, key); err == nil {
+		t.Errorf("expected an error getting a deleted object, but got nil")
+	}
+}
+
+func TestMinIOStore_SignedURL(t *testing.T) {
+	ctx := context.Background()
+
+	key := "book-files/signed-url-test.txt"
+	if _, err := bs.Put(ctx, key, bytes.NewBufferString("hello, bookshelf"), "text/plain"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	defer bs.Delete(ctx, key)
+
+	url, err := bs.SignedURL(ctx, key, 5*time.Minute)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if url == "" {
+		t.Errorf("expected a non-empty signed url, but got an empty string")
+	}
+}
@@ -0,0 +1,77 @@
+package events
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/haleyrc/bookshelf/library"
+)
+
+const (
+	Created  = "CREATED"
+	Updated  = "UPDATED"
+	Borrowed = "BORROWED"
+	Returned = "RETURNED"
+	Deleted  = "DELETED"
+)
+
+type EventStore struct {
+	DB *sqlx.DB
+}
+
+// Record inserts event using ext, so callers that want it recorded as part
+// of a larger write can pass the same *sqlx.Tx they're using for that write.
+func (s *EventStore) Record(ctx context.Context, ext sqlx.ExtContext, event *library.BookEvent) error {
+	q := `
+		INSERT INTO events (book_id, event_type, payload, actor, chapter_id, page_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, occurred_at;
+	`
+
+	rows, err := ext.QueryxContext(ctx, q, event.BookID, event.Type, event.Payload, event.Actor, event.ChapterID, event.PageID)
+	if err != nil {
+		return fmt.Errorf("record event: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return fmt.Errorf("record event: no row returned")
+	}
+	if err := rows.Scan(&event.ID, &event.OccurredAt); err != nil {
+		return fmt.Errorf("record event: %w", err)
+	}
+
+	return nil
+}
+
+func (s *EventStore) GetBookHistory(ctx context.Context, bookID int64) ([]library.BookEvent, error) {
+	q := `
+		SELECT id, book_id, event_type, payload, actor, occurred_at, chapter_id, page_id
+		FROM events
+		WHERE book_id = $1
+		ORDER BY id ASC;
+	`
+
+	rows, err := s.DB.QueryxContext(ctx, q, bookID)
+	if err != nil {
+		return nil, fmt.Errorf("get book history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []library.BookEvent{}
+	for rows.Next() {
+		var event library.BookEvent
+		err := rows.Scan(
+			&event.ID, &event.BookID, &event.Type, &event.Payload,
+			&event.Actor, &event.OccurredAt, &event.ChapterID, &event.PageID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("get book history: %w", err)
+		}
+		history = append(history, event)
+	}
+
+	return history, nil
+}
@@ -0,0 +1,41 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/haleyrc/bookshelf/library"
+)
+
+type bookPayload struct {
+	Title string `json:"title"`
+}
+
+// Replay rebuilds the state of a book from its event history, in order.
+// This is meant as a way to reconstruct a book independent of whatever the
+// books table currently holds, e.g. to check the two agree.
+func Replay(history []library.BookEvent) (*library.Book, error) {
+	var book *library.Book
+
+	for _, event := range history {
+		switch event.Type {
+		case Created, Updated:
+			var payload bookPayload
+			if err := json.Unmarshal(event.Payload, &payload); err != nil {
+				return nil, fmt.Errorf("replay: %w", err)
+			}
+			if book == nil {
+				book = &library.Book{ID: event.BookID}
+			}
+			book.Title = payload.Title
+		case Deleted:
+			book = nil
+		}
+	}
+
+	if book == nil {
+		return nil, fmt.Errorf("replay: no book could be rebuilt from history")
+	}
+
+	return book, nil
+}
@@ -0,0 +1,68 @@
+package events_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/haleyrc/bookshelf/library"
+	"github.com/haleyrc/bookshelf/library/events"
+)
+
+func TestReplay(t *testing.T) {
+	testcases := map[string]struct {
+		History   []library.BookEvent
+		WantTitle string
+		ShouldErr bool
+	}{
+		"No Events": {
+			History:   nil,
+			ShouldErr: true,
+		},
+		"Created": {
+			History:   []library.BookEvent{{BookID: 1, Type: events.Created, Payload: payload("Dune")}},
+			WantTitle: "Dune",
+		},
+		"Created Then Updated": {
+			History: []library.BookEvent{
+				{BookID: 1, Type: events.Created, Payload: payload("Dune")},
+				{BookID: 1, Type: events.Updated, Payload: payload("Dune Messiah")},
+			},
+			WantTitle: "Dune Messiah",
+		},
+		"Created Then Deleted": {
+			History: []library.BookEvent{
+				{BookID: 1, Type: events.Created, Payload: payload("Dune")},
+				{BookID: 1, Type: events.Deleted},
+			},
+			ShouldErr: true,
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			book, err := events.Replay(tc.History)
+			if tc.ShouldErr {
+				if err == nil {
+					t.Fatal("expected an error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+			if book.Title != tc.WantTitle {
+				t.Errorf("expected title %q, but got %q", tc.WantTitle, book.Title)
+			}
+		})
+	}
+}
+
+func payload(title string) json.RawMessage {
+	b, err := json.Marshal(struct {
+		Title string `json:"title"`
+	}{Title: title})
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
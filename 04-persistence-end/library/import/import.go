@@ -0,0 +1,195 @@
+// Package importer walks a directory tree of ebooks and their sidecar
+// metadata and imports them as library.Books, in the style of a Calibre
+// library import.
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/haleyrc/bookshelf/library"
+	"github.com/haleyrc/bookshelf/library/service"
+)
+
+// bookExtensions are the file extensions Import treats as book files. Any
+// other extension is left alone.
+var bookExtensions = map[string]bool{
+	".epub": true,
+	".pdf":  true,
+	".mobi": true,
+}
+
+// skippedStems are sidecar/art files that live next to a book but aren't
+// books themselves and aren't consumed by anything, so Import counts them
+// as skipped rather than attempting to import them or treating them as
+// import failures.
+var skippedStems = map[string]bool{
+	"cover": true,
+}
+
+// consumedStems are sidecar files that live next to a book and are read by
+// loadMetadata, so Import passes over them silently rather than counting
+// them as skipped.
+var consumedStems = map[string]bool{
+	"metadata": true,
+}
+
+// Service is the subset of LibraryService Importer depends on.
+type Service interface {
+	AddBook(ctx context.Context, req service.AddBookRequest) (service.AddBookResponse, error)
+}
+
+// FileRecorder is the subset of LibraryStore Importer depends on to track
+// the on-disk location of each file it imports.
+type FileRecorder interface {
+	CreateBookFile(ctx context.Context, file *library.BookFile) error
+}
+
+// ImportReport summarizes the result of a single Import run.
+type ImportReport struct {
+	Imported int
+	Skipped  int
+	Failed   []string
+}
+
+// Importer imports books from a filesystem tree into a LibraryService.
+type Importer struct {
+	Service Service
+	Files   FileRecorder
+}
+
+// Import walks fsys and imports every recognized book file it finds. A
+// failure importing one book is recorded in the returned report rather
+// than aborting the rest of the walk.
+func (imp *Importer) Import(ctx context.Context, fsys fs.FS) (ImportReport, error) {
+	ic := newImportContext()
+
+	var report ImportReport
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		ext := path.Ext(d.Name())
+		stem := strings.TrimSuffix(d.Name(), ext)
+		if skippedStems[strings.ToLower(stem)] {
+			report.Skipped++
+			return nil
+		}
+		if consumedStems[strings.ToLower(stem)] {
+			return nil
+		}
+		if !bookExtensions[strings.ToLower(ext)] {
+			return nil
+		}
+
+		if err := imp.importBook(ctx, fsys, ic, p); err != nil {
+			report.Failed = append(report.Failed, p)
+			return nil
+		}
+		report.Imported++
+		return nil
+	})
+	if err != nil {
+		return ImportReport{}, fmt.Errorf("import: %w", err)
+	}
+
+	return report, nil
+}
+
+// importBook reads the sidecar metadata for the book at p, resolves its
+// author through ic, and adds it via the service.
+func (imp *Importer) importBook(ctx context.Context, fsys fs.FS, ic *importContext, p string) error {
+	meta, err := loadMetadata(fsys, path.Dir(p))
+	if err != nil {
+		return fmt.Errorf("import %s: %w", p, err)
+	}
+	if meta == nil {
+		return fmt.Errorf("import %s: no metadata.opf or metadata.json found", p)
+	}
+
+	req := service.AddBookRequest{
+		Title:       meta.Title,
+		Tags:        meta.Tags,
+		Publisher:   meta.Publisher,
+		Series:      meta.Series,
+		SeriesIndex: meta.SeriesIndex,
+	}
+	if len(meta.Authors) > 0 {
+		author := meta.Authors[0]
+		if id, ok := ic.resolvedAuthor(author); ok {
+			req.AuthorID = id
+		} else {
+			req.Forename = author.Forename
+			req.Lastname = author.Lastname
+		}
+	}
+
+	resp, err := imp.Service.AddBook(ctx, req)
+	if err != nil {
+		return fmt.Errorf("import %s: %w", p, err)
+	}
+
+	if len(meta.Authors) > 0 && len(resp.Book.Authors) > 0 {
+		ic.rememberAuthor(meta.Authors[0], resp.Book.Authors[0].ID)
+	}
+
+	size := int64(0)
+	if info, err := fs.Stat(fsys, p); err == nil {
+		size = info.Size()
+	}
+	file := library.BookFile{
+		BookID: resp.Book.ID,
+		Path:   p,
+		Format: strings.TrimPrefix(strings.ToLower(path.Ext(p)), "."),
+		Size:   size,
+	}
+	if err := imp.Files.CreateBookFile(ctx, &file); err != nil {
+		return fmt.Errorf("import %s: %w", p, err)
+	}
+
+	return nil
+}
+
+// importContext memoizes the authors a single Import run has already
+// created so repeated references to the same author across books resolve
+// to the same row instead of creating duplicates.
+//
+// Tags, publishers, and series don't need the same treatment: unlike
+// AddBookRequest, which takes an AuthorID once an author is known, it always
+// takes tag/publisher/series names, and AddBook upserts those by name on
+// every call, so passing the same name again already resolves to the same
+// row without anything to memoize here.
+type importContext struct {
+	authorIDs map[string]int64
+}
+
+func newImportContext() *importContext {
+	return &importContext{authorIDs: map[string]int64{}}
+}
+
+func (ic *importContext) resolvedAuthor(author metadataAuthor) (int64, bool) {
+	id, ok := ic.authorIDs[authorKey(author)]
+	return id, ok
+}
+
+func (ic *importContext) rememberAuthor(author metadataAuthor, id int64) {
+	ic.authorIDs[authorKey(author)] = id
+}
+
+// authorKey prefers the source's own id, when the sidecar supplies one,
+// over the name so the same author is recognized across books even when
+// the name is spelled differently. Authors that share a name still resolve
+// to one row, since AddBook upserts authors by forename and lastname.
+func authorKey(author metadataAuthor) string {
+	if author.SourceID != "" {
+		return author.SourceID
+	}
+	return author.Forename + "|" + author.Lastname
+}
@@ -0,0 +1,113 @@
+package importer_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/haleyrc/bookshelf/library"
+	importer "github.com/haleyrc/bookshelf/library/import"
+	"github.com/haleyrc/bookshelf/library/service"
+)
+
+func TestImporter_Import(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dune/dune.epub": &fstest.MapFile{Data: []byte("fake epub")},
+		"dune/metadata.json": &fstest.MapFile{Data: []byte(`{
+			"title": "Dune",
+			"authors": [{"id": "herbert", "forename": "Frank", "lastname": "Herbert"}],
+			"tags": ["Science Fiction", "Classics"],
+			"publisher": "Ace Books",
+			"series": "Dune",
+			"series_index": 1
+		}`)},
+		"dune/cover.jpg": &fstest.MapFile{Data: []byte("fake cover")},
+
+		"dune-messiah/dune-messiah.epub": &fstest.MapFile{Data: []byte("fake epub")},
+		"dune-messiah/metadata.json": &fstest.MapFile{Data: []byte(`{
+			"title": "Dune Messiah",
+			"authors": [{"id": "herbert", "forename": "Frank", "lastname": "Herbert"}]
+		}`)},
+
+		"no-metadata/mystery.pdf": &fstest.MapFile{Data: []byte("fake pdf")},
+
+		"not-a-book/notes.txt": &fstest.MapFile{Data: []byte("not a book")},
+	}
+
+	svc := &fakeService{}
+	files := &fakeFileRecorder{}
+	imp := &importer.Importer{Service: svc, Files: files}
+
+	report, err := imp.Import(context.Background(), fsys)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if report.Imported != 2 {
+		t.Errorf("expected 2 books imported, but got %d", report.Imported)
+	}
+	if report.Skipped != 1 {
+		t.Errorf("expected 1 file skipped, but got %d", report.Skipped)
+	}
+	if len(report.Failed) != 1 || report.Failed[0] != "no-metadata/mystery.pdf" {
+		t.Errorf("expected no-metadata/mystery.pdf to be reported as failed, but got %v", report.Failed)
+	}
+
+	if len(svc.requests) != 2 {
+		t.Fatalf("expected 2 calls to AddBook, but got %d", len(svc.requests))
+	}
+	if svc.requests[1].AuthorID == 0 {
+		t.Errorf("expected the second book to reuse the first book's author id, but AddBookRequest.AuthorID was 0")
+	}
+
+	dune := svc.requests[0]
+	if len(dune.Tags) != 2 || dune.Tags[0] != "Science Fiction" || dune.Tags[1] != "Classics" {
+		t.Errorf("expected Dune's tags to be parsed from metadata.json, but got %v", dune.Tags)
+	}
+	if dune.Publisher != "Ace Books" {
+		t.Errorf("expected Dune's publisher %q, but got %q", "Ace Books", dune.Publisher)
+	}
+	if dune.Series != "Dune" {
+		t.Errorf("expected Dune's series %q, but got %q", "Dune", dune.Series)
+	}
+	if dune.SeriesIndex == nil || *dune.SeriesIndex != 1 {
+		t.Errorf("expected Dune's series index 1, but got %v", dune.SeriesIndex)
+	}
+
+	if len(files.created) != 2 {
+		t.Fatalf("expected 2 book files recorded, but got %d", len(files.created))
+	}
+	if files.created[0].Format != "epub" {
+		t.Errorf("expected format %q, but got %q", "epub", files.created[0].Format)
+	}
+}
+
+type fakeService struct {
+	requests []service.AddBookRequest
+	nextID   int64
+}
+
+func (s *fakeService) AddBook(ctx context.Context, req service.AddBookRequest) (service.AddBookResponse, error) {
+	s.requests = append(s.requests, req)
+
+	s.nextID++
+	book := &library.Book{ID: s.nextID, Title: req.Title}
+
+	authorID := req.AuthorID
+	if authorID == 0 {
+		authorID = s.nextID + 1000
+	}
+	book.Authors = []library.Author{{ID: authorID, Forename: req.Forename, Lastname: req.Lastname}}
+
+	return service.AddBookResponse{Book: book}, nil
+}
+
+type fakeFileRecorder struct {
+	created []library.BookFile
+}
+
+func (f *fakeFileRecorder) CreateBookFile(ctx context.Context, file *library.BookFile) error {
+	file.ID = int64(len(f.created)) + 1
+	f.created = append(f.created, *file)
+	return nil
+}
@@ -0,0 +1,147 @@
+package importer
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// metadataAuthor is one author entry from a sidecar file. SourceID is
+// whatever identifier the source format uses (a Calibre OPF id, say) and
+// lets importContext recognize the same author across books even when the
+// forename/lastname spelling varies slightly.
+type metadataAuthor struct {
+	SourceID string
+	Forename string
+	Lastname string
+}
+
+// metadata is the book information importBook needs, regardless of which
+// sidecar format it came from.
+type metadata struct {
+	Title       string
+	Authors     []metadataAuthor
+	Tags        []string
+	Publisher   string
+	Series      string
+	SeriesIndex *float64
+}
+
+// loadMetadata looks for a metadata.opf then a metadata.json alongside a
+// book file in dir and parses whichever is found first. It returns a nil
+// metadata, nil error if neither sidecar exists, so the caller can decide
+// how to treat a book with no metadata.
+func loadMetadata(fsys fs.FS, dir string) (*metadata, error) {
+	if opf, err := fs.ReadFile(fsys, path.Join(dir, "metadata.opf")); err == nil {
+		return parseOPF(opf)
+	}
+	if js, err := fs.ReadFile(fsys, path.Join(dir, "metadata.json")); err == nil {
+		return parseJSON(js)
+	}
+	return nil, nil
+}
+
+type opfPackage struct {
+	Metadata struct {
+		Title   string `xml:"title"`
+		Creator []struct {
+			ID   string `xml:"id,attr"`
+			Name string `xml:",chardata"`
+		} `xml:"creator"`
+		Subject   []string `xml:"subject"`
+		Publisher string   `xml:"publisher"`
+		Meta      []struct {
+			Name    string `xml:"name,attr"`
+			Content string `xml:"content,attr"`
+		} `xml:"meta"`
+	} `xml:"metadata"`
+}
+
+func parseOPF(data []byte) (*metadata, error) {
+	var pkg opfPackage
+	if err := xml.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("parse opf: %w", err)
+	}
+
+	meta := &metadata{
+		Title:     pkg.Metadata.Title,
+		Tags:      pkg.Metadata.Subject,
+		Publisher: pkg.Metadata.Publisher,
+	}
+	for _, creator := range pkg.Metadata.Creator {
+		forename, lastname := splitName(creator.Name)
+		meta.Authors = append(meta.Authors, metadataAuthor{
+			SourceID: creator.ID,
+			Forename: forename,
+			Lastname: lastname,
+		})
+	}
+
+	// Calibre doesn't have dedicated series elements; it stashes them in
+	// <meta name="calibre:series"/content="calibre:series_index"> instead.
+	for _, m := range pkg.Metadata.Meta {
+		switch m.Name {
+		case "calibre:series":
+			meta.Series = m.Content
+		case "calibre:series_index":
+			if index, err := strconv.ParseFloat(m.Content, 64); err == nil {
+				meta.SeriesIndex = &index
+			}
+		}
+	}
+
+	return meta, nil
+}
+
+type jsonMetadata struct {
+	Title   string `json:"title"`
+	Authors []struct {
+		ID       string `json:"id"`
+		Forename string `json:"forename"`
+		Lastname string `json:"lastname"`
+	} `json:"authors"`
+	Tags        []string `json:"tags"`
+	Publisher   string   `json:"publisher"`
+	Series      string   `json:"series"`
+	SeriesIndex *float64 `json:"series_index"`
+}
+
+func parseJSON(data []byte) (*metadata, error) {
+	var raw jsonMetadata
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse metadata.json: %w", err)
+	}
+
+	meta := &metadata{
+		Title:       raw.Title,
+		Tags:        raw.Tags,
+		Publisher:   raw.Publisher,
+		Series:      raw.Series,
+		SeriesIndex: raw.SeriesIndex,
+	}
+	for _, author := range raw.Authors {
+		meta.Authors = append(meta.Authors, metadataAuthor{
+			SourceID: author.ID,
+			Forename: author.Forename,
+			Lastname: author.Lastname,
+		})
+	}
+
+	return meta, nil
+}
+
+// splitName does the same best-effort forename/lastname split the authors
+// migration used on the old free-text author column: everything up to the
+// first space is the forename, the rest is the lastname.
+func splitName(name string) (forename, lastname string) {
+	name = strings.TrimSpace(name)
+	idx := strings.IndexByte(name, ' ')
+	if idx < 0 {
+		return name, ""
+	}
+	return name[:idx], strings.TrimSpace(name[idx+1:])
+}
@@ -0,0 +1,132 @@
+package library
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type Book struct {
+	ID      int64
+	Title   string
+	Authors []Author
+	Tags    []Tag
+
+	// Publisher and Series are nil when the book has neither. SeriesIndex is
+	// only meaningful when Series is set.
+	Publisher   *Publisher
+	Series      *Series
+	SeriesIndex *float64
+
+	// Files is only populated when a caller asks LibraryService to hydrate
+	// it, e.g. GetBookByIDRequest.WithDownloadURLs.
+	Files []BookFile
+}
+
+type Author struct {
+	ID       int64
+	Forename string
+	Lastname string
+	Created  time.Time
+	Updated  time.Time
+}
+
+// Tag is a free-form label a book can be filed under. Unlike Author,
+// Publisher, and Series, a book can have any number of tags.
+type Tag struct {
+	ID   int64
+	Name string
+}
+
+// Publisher is upserted by name, the same as Author is upserted by
+// forename/lastname, so two books from the same publisher share one row.
+type Publisher struct {
+	ID   int64
+	Name string
+}
+
+// Series is upserted by name. A book's place in its series is tracked
+// separately as Book.SeriesIndex, since the same series can hold many books.
+type Series struct {
+	ID   int64
+	Name string
+}
+
+// Transaction is the set of writes LibraryService performs atomically when
+// adding a book. LibraryStore and any in-memory test double implement it so
+// the service can upsert an author, tags, a publisher, and a series, and
+// link them all to a book, without depending on a particular store's
+// transaction type.
+type Transaction interface {
+	CreateBook(ctx context.Context, book *Book) error
+	CreateAuthor(ctx context.Context, author *Author) error
+	AttachAuthor(ctx context.Context, bookID, authorID int64) error
+	CreateTag(ctx context.Context, tag *Tag) error
+	AttachTag(ctx context.Context, bookID, tagID int64) error
+	CreatePublisher(ctx context.Context, publisher *Publisher) error
+	AttachPublisher(ctx context.Context, bookID, publisherID int64) error
+	CreateSeries(ctx context.Context, series *Series) error
+	AttachSeries(ctx context.Context, bookID, seriesID int64, index *float64) error
+	Commit() error
+	Rollback() error
+}
+
+// BookEvent is an entry in a book's lifecycle log. ChapterID and PageID are
+// nullable so finer-grained events can be recorded once books gain chapter
+// and page structure, without a schema change to this table.
+type BookEvent struct {
+	ID         int64
+	BookID     int64
+	Type       string
+	Payload    json.RawMessage
+	Actor      string
+	OccurredAt time.Time
+	ChapterID  *int64
+	PageID     *int64
+}
+
+// BookFile is a file associated with a book, e.g. an imported EPUB or a
+// cover image. A book can have more than one, one per format or kind. Path
+// is set for files the importer found on disk; ObjectKey and ContentType
+// are set for files uploaded to blob storage. DownloadURL is never
+// persisted - it's filled in by LibraryService when a caller asks for it.
+type BookFile struct {
+	ID          int64
+	BookID      int64
+	Kind        string
+	Path        string
+	Format      string
+	ObjectKey   string
+	ContentType string
+	Size        int64
+	Created     time.Time
+	DownloadURL string
+}
+
+// Sort fields GetBooksQuery accepts.
+const (
+	SortByID     = "id"
+	SortByTitle  = "title"
+	SortByAuthor = "author"
+)
+
+// GetBooksQuery describes one page of a filtered, sorted book listing.
+// Cursor is opaque: pass GetBooksResult.NextCursor from the previous page
+// back in verbatim to get the next one, and leave it blank for the first
+// page. A zero Limit means "no limit".
+type GetBooksQuery struct {
+	Limit         int
+	Cursor        string
+	TitleContains string
+	AuthorEquals  string
+	SortBy        string
+	SortDesc      bool
+}
+
+// GetBooksResult is one page of books plus the cursor for the next page.
+// NextCursor is empty once there are no more pages.
+type GetBooksResult struct {
+	Books      []*Book
+	NextCursor string
+	Total      int64
+}
@@ -0,0 +1,376 @@
+package proptest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/haleyrc/bookshelf/library"
+	"github.com/haleyrc/bookshelf/library/events"
+)
+
+type memStore struct {
+	mu            sync.Mutex
+	books         map[int64]*library.Book
+	authors       map[int64]*library.Author
+	tags          map[int64]*library.Tag
+	publishers    map[int64]*library.Publisher
+	series        map[int64]*library.Series
+	bookAuthor    map[int64][]int64
+	bookTags      map[int64][]int64
+	bookPublisher map[int64]int64
+	bookSeries    map[int64]int64
+	bookSeriesIdx map[int64]*float64
+	bookEvents    map[int64][]library.BookEvent
+	bookFiles     map[int64][]*library.BookFile
+	nextBookID    int64
+	nextAuthID    int64
+	nextTagID     int64
+	nextPublishID int64
+	nextSeriesID  int64
+	nextEventID   int64
+	nextFileID    int64
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		books:         map[int64]*library.Book{},
+		authors:       map[int64]*library.Author{},
+		tags:          map[int64]*library.Tag{},
+		publishers:    map[int64]*library.Publisher{},
+		series:        map[int64]*library.Series{},
+		bookAuthor:    map[int64][]int64{},
+		bookTags:      map[int64][]int64{},
+		bookPublisher: map[int64]int64{},
+		bookSeries:    map[int64]int64{},
+		bookSeriesIdx: map[int64]*float64{},
+		bookEvents:    map[int64][]library.BookEvent{},
+		bookFiles:     map[int64][]*library.BookFile{},
+	}
+}
+
+func (s *memStore) BeginTx(ctx context.Context) (library.Transaction, error) {
+	return &memTx{store: s}, nil
+}
+
+func (s *memStore) GetAuthorByID(ctx context.Context, id int64) (*library.Author, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	author, ok := s.authors[id]
+	if !ok {
+		return nil, fmt.Errorf("get author by id: no author with id %d", id)
+	}
+	return author, nil
+}
+
+func (s *memStore) GetBookByID(ctx context.Context, id int64) (*library.Book, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	book, ok := s.books[id]
+	if !ok {
+		return nil, fmt.Errorf("get book by id: no book with id %d", id)
+	}
+	return s.hydrate(book), nil
+}
+
+func (s *memStore) GetBookHistory(ctx context.Context, bookID int64) ([]library.BookEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := make([]library.BookEvent, len(s.bookEvents[bookID]))
+	copy(history, s.bookEvents[bookID])
+	return history, nil
+}
+
+func (s *memStore) CreateBookFile(ctx context.Context, file *library.BookFile) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if file.Kind == "" {
+		file.Kind = "book"
+	}
+	s.nextFileID++
+	file.ID = s.nextFileID
+	s.bookFiles[file.BookID] = append(s.bookFiles[file.BookID], file)
+	return nil
+}
+
+func (s *memStore) GetBookFilesForBook(ctx context.Context, bookID int64) ([]*library.BookFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files := make([]*library.BookFile, len(s.bookFiles[bookID]))
+	copy(files, s.bookFiles[bookID])
+	return files, nil
+}
+
+func (s *memStore) GetBooks(ctx context.Context, q library.GetBooksQuery) (library.GetBooksResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	books := make([]*library.Book, 0, len(s.books))
+	for id := int64(1); id <= s.nextBookID; id++ {
+		book, ok := s.books[id]
+		if !ok {
+			continue
+		}
+		hydrated := s.hydrate(book)
+		if q.TitleContains != "" && !strings.Contains(hydrated.Title, q.TitleContains) {
+			continue
+		}
+		if q.AuthorEquals != "" && !hasMatchingAuthor(hydrated, q.AuthorEquals) {
+			continue
+		}
+		books = append(books, hydrated)
+	}
+
+	sortMemBooks(books, q.SortBy, q.SortDesc)
+
+	total := int64(len(books))
+	books = skipToMemCursor(books, q.Cursor, q.SortBy)
+
+	hasMore := q.Limit > 0 && len(books) > q.Limit
+	if hasMore {
+		books = books[:q.Limit]
+	}
+
+	result := library.GetBooksResult{Books: books, Total: total}
+	if hasMore {
+		result.NextCursor = memSortKey(books[len(books)-1], q.SortBy)
+	}
+	return result, nil
+}
+
+// hasMatchingAuthor reports whether any of book's authors has the given
+// forename, lastname, or "forename lastname".
+func hasMatchingAuthor(book *library.Book, equals string) bool {
+	for _, author := range book.Authors {
+		if author.Forename == equals || author.Lastname == equals || author.Forename+" "+author.Lastname == equals {
+			return true
+		}
+	}
+	return false
+}
+
+// memSortKey returns the field a given sort is keyed on, used both to
+// order books and to build the cursor that picks up after the last one.
+func memSortKey(book *library.Book, sortBy string) string {
+	switch sortBy {
+	case library.SortByTitle:
+		return book.Title
+	case library.SortByAuthor:
+		if len(book.Authors) > 0 {
+			return book.Authors[0].Lastname
+		}
+		return ""
+	default:
+		return fmt.Sprintf("%020d", book.ID)
+	}
+}
+
+func sortMemBooks(books []*library.Book, sortBy string, desc bool) {
+	sort.SliceStable(books, func(i, j int) bool {
+		less := memSortKey(books[i], sortBy) < memSortKey(books[j], sortBy)
+		if desc {
+			return !less
+		}
+		return less
+	})
+}
+
+// skipToMemCursor drops every book up to and including the one the cursor
+// (the sort key of the last book on the previous page) refers to.
+func skipToMemCursor(books []*library.Book, cursor, sortBy string) []*library.Book {
+	if cursor == "" {
+		return books
+	}
+	for i, book := range books {
+		if memSortKey(book, sortBy) == cursor {
+			return books[i+1:]
+		}
+	}
+	return books
+}
+
+// hydrate returns a copy of book with its authors, tags, publisher, and
+// series filled in, mirroring the joins LibraryStore does for real.
+func (s *memStore) hydrate(book *library.Book) *library.Book {
+	out := *book
+	for _, authorID := range s.bookAuthor[book.ID] {
+		out.Authors = append(out.Authors, *s.authors[authorID])
+	}
+	for _, tagID := range s.bookTags[book.ID] {
+		out.Tags = append(out.Tags, *s.tags[tagID])
+	}
+	if publisherID, ok := s.bookPublisher[book.ID]; ok {
+		publisher := *s.publishers[publisherID]
+		out.Publisher = &publisher
+	}
+	if seriesID, ok := s.bookSeries[book.ID]; ok {
+		series := *s.series[seriesID]
+		out.Series = &series
+		out.SeriesIndex = s.bookSeriesIdx[book.ID]
+	}
+	return &out
+}
+
+// memTx applies its writes straight to the parent memStore. There is
+// nothing to stage in memory, so Commit and Rollback are both no-ops.
+type memTx struct {
+	store *memStore
+}
+
+func (t *memTx) CreateBook(ctx context.Context, book *library.Book) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	t.store.nextBookID++
+	book.ID = t.store.nextBookID
+	t.store.books[book.ID] = &library.Book{ID: book.ID, Title: book.Title}
+
+	payload, err := json.Marshal(struct {
+		Title string `json:"title"`
+	}{Title: book.Title})
+	if err != nil {
+		return fmt.Errorf("create book: %w", err)
+	}
+	t.store.nextEventID++
+	t.store.bookEvents[book.ID] = append(t.store.bookEvents[book.ID], library.BookEvent{
+		ID:      t.store.nextEventID,
+		BookID:  book.ID,
+		Type:    events.Created,
+		Payload: payload,
+	})
+
+	return nil
+}
+
+// CreateAuthor upserts author by name, mirroring LibraryTx.CreateAuthor, so
+// two books sharing an author by name resolve to the same row here too.
+func (t *memTx) CreateAuthor(ctx context.Context, author *library.Author) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	for _, existing := range t.store.authors {
+		if existing.Forename == author.Forename && existing.Lastname == author.Lastname {
+			*author = *existing
+			return nil
+		}
+	}
+
+	t.store.nextAuthID++
+	author.ID = t.store.nextAuthID
+	t.store.authors[author.ID] = &library.Author{ID: author.ID, Forename: author.Forename, Lastname: author.Lastname}
+	return nil
+}
+
+func (t *memTx) AttachAuthor(ctx context.Context, bookID, authorID int64) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	if _, ok := t.store.books[bookID]; !ok {
+		return fmt.Errorf("attach author: no book with id %d", bookID)
+	}
+	if _, ok := t.store.authors[authorID]; !ok {
+		return fmt.Errorf("attach author: no author with id %d", authorID)
+	}
+	t.store.bookAuthor[bookID] = append(t.store.bookAuthor[bookID], authorID)
+	return nil
+}
+
+// CreateTag upserts tag by name, mirroring LibraryTx.CreateTag.
+func (t *memTx) CreateTag(ctx context.Context, tag *library.Tag) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	for _, existing := range t.store.tags {
+		if existing.Name == tag.Name {
+			*tag = *existing
+			return nil
+		}
+	}
+
+	t.store.nextTagID++
+	tag.ID = t.store.nextTagID
+	t.store.tags[tag.ID] = &library.Tag{ID: tag.ID, Name: tag.Name}
+	return nil
+}
+
+func (t *memTx) AttachTag(ctx context.Context, bookID, tagID int64) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	for _, existing := range t.store.bookTags[bookID] {
+		if existing == tagID {
+			return nil
+		}
+	}
+	t.store.bookTags[bookID] = append(t.store.bookTags[bookID], tagID)
+	return nil
+}
+
+// CreatePublisher upserts publisher by name, mirroring LibraryTx.CreatePublisher.
+func (t *memTx) CreatePublisher(ctx context.Context, publisher *library.Publisher) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	for _, existing := range t.store.publishers {
+		if existing.Name == publisher.Name {
+			*publisher = *existing
+			return nil
+		}
+	}
+
+	t.store.nextPublishID++
+	publisher.ID = t.store.nextPublishID
+	t.store.publishers[publisher.ID] = &library.Publisher{ID: publisher.ID, Name: publisher.Name}
+	return nil
+}
+
+func (t *memTx) AttachPublisher(ctx context.Context, bookID, publisherID int64) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	t.store.bookPublisher[bookID] = publisherID
+	return nil
+}
+
+// CreateSeries upserts series by name, mirroring LibraryTx.CreateSeries.
+func (t *memTx) CreateSeries(ctx context.Context, series *library.Series) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	for _, existing := range t.store.series {
+		if existing.Name == series.Name {
+			*series = *existing
+			return nil
+		}
+	}
+
+	t.store.nextSeriesID++
+	series.ID = t.store.nextSeriesID
+	t.store.series[series.ID] = &library.Series{ID: series.ID, Name: series.Name}
+	return nil
+}
+
+func (t *memTx) AttachSeries(ctx context.Context, bookID, seriesID int64, index *float64) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	t.store.bookSeries[bookID] = seriesID
+	t.store.bookSeriesIdx[bookID] = index
+	return nil
+}
+
+func (t *memTx) Commit() error {
+	return nil
+}
+
+func (t *memTx) Rollback() error {
+	return nil
+}
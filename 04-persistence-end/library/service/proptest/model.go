@@ -0,0 +1,111 @@
+package proptest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/haleyrc/bookshelf/library"
+	"github.com/haleyrc/bookshelf/library/service"
+)
+
+type model struct {
+	books  map[int64]modelBook
+	order  []int64
+	nextID int64
+}
+
+type modelBook struct {
+	Title    string
+	Forename string
+	Lastname string
+}
+
+func newModel() *model {
+	return &model{books: map[int64]modelBook{}}
+}
+
+type command interface {
+	run(ctx context.Context, svc *service.LibraryService, m *model) error
+}
+
+type addBook struct {
+	Title    string
+	Forename string
+	Lastname string
+}
+
+func (c addBook) run(ctx context.Context, svc *service.LibraryService, m *model) error {
+	resp, err := svc.AddBook(ctx, service.AddBookRequest{Title: c.Title, Forename: c.Forename, Lastname: c.Lastname})
+	if c.Title == "" || c.Forename == "" || c.Lastname == "" {
+		if err == nil {
+			return fmt.Errorf("addBook(%q, %q, %q): expected an error, but got nil", c.Title, c.Forename, c.Lastname)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("addBook(%q, %q, %q): unexpected error: %w", c.Title, c.Forename, c.Lastname, err)
+	}
+
+	if resp.Book.ID <= m.nextID {
+		return fmt.Errorf("addBook: expected id greater than %d, but got %d", m.nextID, resp.Book.ID)
+	}
+	m.nextID = resp.Book.ID
+	m.books[resp.Book.ID] = modelBook{Title: c.Title, Forename: c.Forename, Lastname: c.Lastname}
+	m.order = append(m.order, resp.Book.ID)
+	return nil
+}
+
+type getBookByID struct {
+	ID int64
+}
+
+func (c getBookByID) run(ctx context.Context, svc *service.LibraryService, m *model) error {
+	resp, err := svc.GetBookByID(ctx, service.GetBookByIDRequest{ID: c.ID})
+
+	want, ok := m.books[c.ID]
+	if !ok {
+		if err == nil {
+			return fmt.Errorf("getBookByID(%d): expected an error for an unknown id, but got nil", c.ID)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("getBookByID(%d): unexpected error: %w", c.ID, err)
+	}
+	if err := checkBook(resp.Book, want); err != nil {
+		return fmt.Errorf("getBookByID(%d): %w", c.ID, err)
+	}
+	return nil
+}
+
+type getBooks struct{}
+
+func (c getBooks) run(ctx context.Context, svc *service.LibraryService, m *model) error {
+	resp, err := svc.ListBooks(ctx, service.ListBooksRequest{})
+	if err != nil {
+		return fmt.Errorf("getBooks: unexpected error: %w", err)
+	}
+	if len(resp.Books) != len(m.order) {
+		return fmt.Errorf("getBooks: expected %d books, but got %d", len(m.order), len(resp.Books))
+	}
+
+	for i, id := range m.order {
+		if err := checkBook(resp.Books[i], m.books[id]); err != nil {
+			return fmt.Errorf("getBooks[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func checkBook(got *library.Book, want modelBook) error {
+	if got.Title != want.Title {
+		return fmt.Errorf("expected title %q, but got %q", want.Title, got.Title)
+	}
+	if len(got.Authors) != 1 {
+		return fmt.Errorf("expected exactly one author, but got %d", len(got.Authors))
+	}
+	if got.Authors[0].Forename != want.Forename || got.Authors[0].Lastname != want.Lastname {
+		return fmt.Errorf("expected author %s %s, but got %s %s", want.Forename, want.Lastname, got.Authors[0].Forename, got.Authors[0].Lastname)
+	}
+	return nil
+}
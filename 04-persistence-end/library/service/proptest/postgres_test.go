@@ -0,0 +1,106 @@
+//go:build postgres
+
+package proptest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+	"pgregory.net/rapid"
+
+	"github.com/haleyrc/bookshelf/library"
+	"github.com/haleyrc/bookshelf/library/events"
+	"github.com/haleyrc/bookshelf/library/service"
+	"github.com/haleyrc/bookshelf/library/store"
+)
+
+func TestLibraryService_Stateful_Postgres(t *testing.T) {
+	path := filepath.Join("..", "..", "..", ".env")
+	godotenv.Load(path)
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("set the TEST_DATABASE_URL environment variable to run this test suite")
+	}
+	db := sqlx.MustConnect("postgres", url)
+	defer db.Close()
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var createdBooks, createdAuthors []int64
+		rs := &recordingStore{
+			LibraryStore:   &store.LibraryStore{DB: db, Events: &events.EventStore{DB: db}},
+			createdBooks:   &createdBooks,
+			createdAuthors: &createdAuthors,
+		}
+
+		// Every run starts from an empty model, so the rows a run created
+		// are deleted before the next run starts.
+		defer func() {
+			for _, id := range createdBooks {
+				if err := deleteRow(context.Background(), db, "books", id); err != nil {
+					rt.Log("failed to clean up after run:", err)
+				}
+			}
+			for _, id := range createdAuthors {
+				if err := deleteRow(context.Background(), db, "authors", id); err != nil {
+					rt.Log("failed to clean up after run:", err)
+				}
+			}
+		}()
+
+		svc := &service.LibraryService{Store: rs}
+		checkStateful(rt, svc)
+	})
+}
+
+func deleteRow(ctx context.Context, db *sqlx.DB, table string, id int64) error {
+	q := fmt.Sprintf(`DELETE FROM %s WHERE id = $1;`, table)
+	if _, err := db.ExecContext(ctx, q, id); err != nil {
+		return fmt.Errorf("delete %s: %w", table, err)
+	}
+	return nil
+}
+
+// recordingStore wraps store.LibraryStore so the test can track every book
+// and author created during a run and clean them up afterward.
+type recordingStore struct {
+	*store.LibraryStore
+	createdBooks   *[]int64
+	createdAuthors *[]int64
+}
+
+func (s *recordingStore) BeginTx(ctx context.Context) (library.Transaction, error) {
+	tx, err := s.LibraryStore.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingTx{Transaction: tx, createdBooks: s.createdBooks, createdAuthors: s.createdAuthors}, nil
+}
+
+type recordingTx struct {
+	library.Transaction
+	createdBooks   *[]int64
+	createdAuthors *[]int64
+}
+
+func (t *recordingTx) CreateBook(ctx context.Context, book *library.Book) error {
+	if err := t.Transaction.CreateBook(ctx, book); err != nil {
+		return err
+	}
+	*t.createdBooks = append(*t.createdBooks, book.ID)
+	return nil
+}
+
+func (t *recordingTx) CreateAuthor(ctx context.Context, author *library.Author) error {
+	if err := t.Transaction.CreateAuthor(ctx, author); err != nil {
+		return err
+	}
+	*t.createdAuthors = append(*t.createdAuthors, author.ID)
+	return nil
+}
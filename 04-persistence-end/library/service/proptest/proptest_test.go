@@ -0,0 +1,51 @@
+package proptest
+
+import (
+	"context"
+	"testing"
+
+	"pgregory.net/rapid"
+
+	"github.com/haleyrc/bookshelf/library/service"
+)
+
+func TestLibraryService_Stateful(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		svc := &service.LibraryService{Store: newMemStore()}
+		checkStateful(t, svc)
+	})
+}
+
+// checkStateful draws a random sequence of commands, applies them one at a
+// time against svc, and checks each one against an in-memory model of the
+// expected state. A failing command sequence shrinks like any other rapid
+// property, so a bug reports the smallest sequence that reproduces it.
+func checkStateful(t *rapid.T, svc *service.LibraryService) {
+	ctx := context.Background()
+	m := newModel()
+
+	steps := rapid.IntRange(1, 30).Draw(t, "steps")
+	for i := 0; i < steps; i++ {
+		cmd := genCommand(m).Draw(t, "command")
+		if err := cmd.run(ctx, svc, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func genCommand(m *model) *rapid.Generator[command] {
+	return rapid.Custom(func(t *rapid.T) command {
+		switch rapid.IntRange(0, 2).Draw(t, "which") {
+		case 0:
+			return addBook{
+				Title:    rapid.StringN(0, 20, -1).Draw(t, "title"),
+				Forename: rapid.StringN(0, 20, -1).Draw(t, "forename"),
+				Lastname: rapid.StringN(0, 20, -1).Draw(t, "lastname"),
+			}
+		case 1:
+			return getBookByID{ID: rapid.Int64Range(-1, m.nextID+1).Draw(t, "id")}
+		default:
+			return getBooks{}
+		}
+	})
+}
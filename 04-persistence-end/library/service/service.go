@@ -0,0 +1,285 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/haleyrc/bookshelf/library"
+	"github.com/haleyrc/bookshelf/library/blob"
+)
+
+type Store interface {
+	BeginTx(ctx context.Context) (library.Transaction, error)
+	GetAuthorByID(ctx context.Context, id int64) (*library.Author, error)
+	GetBookByID(ctx context.Context, id int64) (*library.Book, error)
+	GetBookHistory(ctx context.Context, bookID int64) ([]library.BookEvent, error)
+	GetBookFilesForBook(ctx context.Context, bookID int64) ([]*library.BookFile, error)
+	GetBooks(ctx context.Context, query library.GetBooksQuery) (library.GetBooksResult, error)
+	CreateBookFile(ctx context.Context, file *library.BookFile) error
+}
+
+// signedURLTTL is how long a hydrated BookFile.DownloadURL stays valid.
+const signedURLTTL = 15 * time.Minute
+
+type LibraryService struct {
+	Store Store
+	Blob  blob.BlobStore
+}
+
+type AddBookRequest struct {
+	Title    string
+	AuthorID int64
+	Forename string
+	Lastname string
+
+	// Tags are upserted by name; a book can have any number of them.
+	Tags []string
+
+	// Publisher and Series are upserted by name, same as Forename/Lastname
+	// is for an author. Either may be left blank. SeriesIndex is only used
+	// when Series is set.
+	Publisher   string
+	Series      string
+	SeriesIndex *float64
+}
+
+type AddBookResponse struct {
+	Book *library.Book
+}
+
+func (svc *LibraryService) AddBook(ctx context.Context, req AddBookRequest) (AddBookResponse, error) {
+	if req.Title == "" {
+		return AddBookResponse{}, fmt.Errorf("add book: title is required")
+	}
+	if req.AuthorID == 0 && (req.Forename == "" || req.Lastname == "") {
+		return AddBookResponse{}, fmt.Errorf("add book: an author id or a forename and lastname is required")
+	}
+
+	tx, err := svc.Store.BeginTx(ctx)
+	if err != nil {
+		return AddBookResponse{}, fmt.Errorf("add book: %w", err)
+	}
+
+	book := &library.Book{Title: req.Title}
+	if err := addBook(ctx, tx, req, book); err != nil {
+		tx.Rollback()
+		return AddBookResponse{}, fmt.Errorf("add book: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return AddBookResponse{}, fmt.Errorf("add book: %w", err)
+	}
+
+	return AddBookResponse{Book: book}, nil
+}
+
+// addBook creates the book, upserts its author, tags, publisher, and
+// series, and links them all to it, all inside tx, so a failure at any
+// step leaves none of it behind.
+func addBook(ctx context.Context, tx library.Transaction, req AddBookRequest, book *library.Book) error {
+	if err := tx.CreateBook(ctx, book); err != nil {
+		return err
+	}
+
+	author := &library.Author{ID: req.AuthorID, Forename: req.Forename, Lastname: req.Lastname}
+	if author.ID == 0 {
+		if err := tx.CreateAuthor(ctx, author); err != nil {
+			return err
+		}
+	}
+	if err := tx.AttachAuthor(ctx, book.ID, author.ID); err != nil {
+		return err
+	}
+	book.Authors = []library.Author{*author}
+
+	for _, name := range req.Tags {
+		tag := &library.Tag{Name: name}
+		if err := tx.CreateTag(ctx, tag); err != nil {
+			return err
+		}
+		if err := tx.AttachTag(ctx, book.ID, tag.ID); err != nil {
+			return err
+		}
+		book.Tags = append(book.Tags, *tag)
+	}
+
+	if req.Publisher != "" {
+		publisher := &library.Publisher{Name: req.Publisher}
+		if err := tx.CreatePublisher(ctx, publisher); err != nil {
+			return err
+		}
+		if err := tx.AttachPublisher(ctx, book.ID, publisher.ID); err != nil {
+			return err
+		}
+		book.Publisher = publisher
+	}
+
+	if req.Series != "" {
+		series := &library.Series{Name: req.Series}
+		if err := tx.CreateSeries(ctx, series); err != nil {
+			return err
+		}
+		if err := tx.AttachSeries(ctx, book.ID, series.ID, req.SeriesIndex); err != nil {
+			return err
+		}
+		book.Series = series
+		book.SeriesIndex = req.SeriesIndex
+	}
+
+	return nil
+}
+
+type GetBookByIDRequest struct {
+	ID int64
+
+	// WithDownloadURLs asks GetBookByID to also fetch the book's files and,
+	// if the configured Blob supports it, sign a download URL for each one
+	// stored in blob storage (files with no ObjectKey get none).
+	WithDownloadURLs bool
+}
+
+type GetBookByIDResponse struct {
+	Book *library.Book
+}
+
+func (svc *LibraryService) GetBookByID(ctx context.Context, req GetBookByIDRequest) (GetBookByIDResponse, error) {
+	book, err := svc.Store.GetBookByID(ctx, req.ID)
+	if err != nil {
+		return GetBookByIDResponse{}, fmt.Errorf("get book by id: %w", err)
+	}
+
+	if req.WithDownloadURLs {
+		if err := svc.hydrateDownloadURLs(ctx, book); err != nil {
+			return GetBookByIDResponse{}, fmt.Errorf("get book by id: %w", err)
+		}
+	}
+
+	return GetBookByIDResponse{Book: book}, nil
+}
+
+// hydrateDownloadURLs loads book's files and, when svc.Blob can sign URLs,
+// fills in each one's DownloadURL.
+func (svc *LibraryService) hydrateDownloadURLs(ctx context.Context, book *library.Book) error {
+	files, err := svc.Store.GetBookFilesForBook(ctx, book.ID)
+	if err != nil {
+		return err
+	}
+
+	signer, ok := svc.Blob.(blob.URLSigner)
+	for _, file := range files {
+		if ok && file.ObjectKey != "" {
+			url, err := signer.SignedURL(ctx, file.ObjectKey, signedURLTTL)
+			if err != nil {
+				return err
+			}
+			file.DownloadURL = url
+		}
+		book.Files = append(book.Files, *file)
+	}
+
+	return nil
+}
+
+type UploadBookFileRequest struct {
+	BookID      int64
+	Kind        string
+	Filename    string
+	ContentType string
+	Reader      io.Reader
+}
+
+type UploadBookFileResponse struct {
+	File library.BookFile
+}
+
+// UploadBookFile stores req's bytes in blob storage under a key derived
+// from the book id and the content's sha256, then records the resulting
+// file in the store.
+func (svc *LibraryService) UploadBookFile(ctx context.Context, req UploadBookFileRequest) (UploadBookFileResponse, error) {
+	if req.BookID == 0 {
+		return UploadBookFileResponse{}, fmt.Errorf("upload book file: book id is required")
+	}
+	if req.Reader == nil {
+		return UploadBookFileResponse{}, fmt.Errorf("upload book file: reader is required")
+	}
+
+	data, err := io.ReadAll(req.Reader)
+	if err != nil {
+		return UploadBookFileResponse{}, fmt.Errorf("upload book file: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	key := fmt.Sprintf("books/%d/%x%s", req.BookID, sum, path.Ext(req.Filename))
+
+	if _, err := svc.Blob.Put(ctx, key, bytes.NewReader(data), req.ContentType); err != nil {
+		return UploadBookFileResponse{}, fmt.Errorf("upload book file: %w", err)
+	}
+
+	file := library.BookFile{
+		BookID:      req.BookID,
+		Kind:        req.Kind,
+		ObjectKey:   key,
+		ContentType: req.ContentType,
+		Format:      strings.TrimPrefix(path.Ext(req.Filename), "."),
+		Size:        int64(len(data)),
+	}
+	if err := svc.Store.CreateBookFile(ctx, &file); err != nil {
+		return UploadBookFileResponse{}, fmt.Errorf("upload book file: %w", err)
+	}
+
+	return UploadBookFileResponse{File: file}, nil
+}
+
+type GetBookHistoryRequest struct {
+	BookID int64
+}
+
+type GetBookHistoryResponse struct {
+	Events []library.BookEvent
+}
+
+func (svc *LibraryService) GetBookHistory(ctx context.Context, req GetBookHistoryRequest) (GetBookHistoryResponse, error) {
+	events, err := svc.Store.GetBookHistory(ctx, req.BookID)
+	if err != nil {
+		return GetBookHistoryResponse{}, fmt.Errorf("get book history: %w", err)
+	}
+
+	return GetBookHistoryResponse{Events: events}, nil
+}
+
+type ListBooksRequest struct {
+	Limit         int
+	Cursor        string
+	TitleContains string
+	AuthorEquals  string
+	SortBy        string
+	SortDesc      bool
+}
+
+type ListBooksResponse struct {
+	Books      []*library.Book
+	NextCursor string
+	Total      int64
+}
+
+func (svc *LibraryService) ListBooks(ctx context.Context, req ListBooksRequest) (ListBooksResponse, error) {
+	result, err := svc.Store.GetBooks(ctx, library.GetBooksQuery{
+		Limit:         req.Limit,
+		Cursor:        req.Cursor,
+		TitleContains: req.TitleContains,
+		AuthorEquals:  req.AuthorEquals,
+		SortBy:        req.SortBy,
+		SortDesc:      req.SortDesc,
+	})
+	if err != nil {
+		return ListBooksResponse{}, fmt.Errorf("list books: %w", err)
+	}
+
+	return ListBooksResponse{Books: result.Books, NextCursor: result.NextCursor, Total: result.Total}, nil
+}
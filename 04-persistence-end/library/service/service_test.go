@@ -0,0 +1,149 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/haleyrc/bookshelf/library"
+	"github.com/haleyrc/bookshelf/library/service"
+)
+
+func TestLibraryService_UploadBookFile(t *testing.T) {
+	ctx := context.Background()
+
+	testcases := map[string]struct {
+		Request   service.UploadBookFileRequest
+		ShouldErr bool
+	}{
+		"No Book ID": {
+			Request:   service.UploadBookFileRequest{Reader: bytes.NewBufferString("data")},
+			ShouldErr: true,
+		},
+		"No Reader": {
+			Request:   service.UploadBookFileRequest{BookID: 1},
+			ShouldErr: true,
+		},
+		"Valid": {
+			Request: service.UploadBookFileRequest{
+				BookID:      1,
+				Kind:        "book",
+				Filename:    "dune.epub",
+				ContentType: "application/epub+zip",
+				Reader:      bytes.NewBufferString("fake epub bytes"),
+			},
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			store := &fakeStore{}
+			svc := &service.LibraryService{Store: store, Blob: &fakeBlob{}}
+
+			resp, err := svc.UploadBookFile(ctx, tc.Request)
+			if tc.ShouldErr {
+				if err == nil {
+					t.Fatal("expected an error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			if resp.File.ObjectKey == "" {
+				t.Errorf("expected an object key, but got an empty string")
+			}
+			if resp.File.Format != "epub" {
+				t.Errorf("expected format %q, but got %q", "epub", resp.File.Format)
+			}
+			if len(store.created) != 1 {
+				t.Fatalf("expected the file to be recorded in the store, but got %d records", len(store.created))
+			}
+		})
+	}
+}
+
+func TestLibraryService_GetBookByID_WithDownloadURLs(t *testing.T) {
+	ctx := context.Background()
+
+	store := &fakeStore{
+		book: &library.Book{ID: 1, Title: "Dune"},
+		files: []*library.BookFile{
+			{ID: 1, BookID: 1, ObjectKey: "books/1/dune.epub"},
+		},
+	}
+	svc := &service.LibraryService{Store: store, Blob: &fakeBlob{}}
+
+	resp, err := svc.GetBookByID(ctx, service.GetBookByIDRequest{ID: 1, WithDownloadURLs: true})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(resp.Book.Files) != 1 {
+		t.Fatalf("expected exactly one file, but got %d", len(resp.Book.Files))
+	}
+	if resp.Book.Files[0].DownloadURL == "" {
+		t.Errorf("expected a signed download url, but got an empty string")
+	}
+}
+
+type fakeStore struct {
+	book    *library.Book
+	files   []*library.BookFile
+	created []library.BookFile
+}
+
+func (s *fakeStore) BeginTx(ctx context.Context) (library.Transaction, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (s *fakeStore) GetAuthorByID(ctx context.Context, id int64) (*library.Author, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (s *fakeStore) GetBookByID(ctx context.Context, id int64) (*library.Book, error) {
+	if s.book == nil {
+		return nil, fmt.Errorf("no book with id %d", id)
+	}
+	return s.book, nil
+}
+
+func (s *fakeStore) GetBookHistory(ctx context.Context, bookID int64) ([]library.BookEvent, error) {
+	return nil, nil
+}
+
+func (s *fakeStore) GetBookFilesForBook(ctx context.Context, bookID int64) ([]*library.BookFile, error) {
+	return s.files, nil
+}
+
+func (s *fakeStore) GetBooks(ctx context.Context, query library.GetBooksQuery) (library.GetBooksResult, error) {
+	return library.GetBooksResult{}, nil
+}
+
+func (s *fakeStore) CreateBookFile(ctx context.Context, file *library.BookFile) error {
+	file.ID = int64(len(s.created)) + 1
+	s.created = append(s.created, *file)
+	return nil
+}
+
+type fakeBlob struct{}
+
+func (b *fakeBlob) Put(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	return "https://blob.example.com/" + key, nil
+}
+
+func (b *fakeBlob) Get(ctx context.Context, key string) (io.ReadCloser, string, error) {
+	return nil, "", fmt.Errorf("not implemented")
+}
+
+func (b *fakeBlob) Delete(ctx context.Context, key string) error {
+	return nil
+}
+
+func (b *fakeBlob) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return "https://blob.example.com/" + key + "?signed=1", nil
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/haleyrc/bookshelf/library"
+)
+
+// cursor is the decoded form of a GetBooksQuery.Cursor / GetBooksResult's
+// NextCursor: the sort key and id of the last row on the previous page, so
+// the next page's WHERE clause can pick up right after it.
+type cursor struct {
+	LastID  int64  `json:"last_id"`
+	LastKey string `json:"last_key"`
+}
+
+func encodeCursor(c cursor) string {
+	data, err := json.Marshal(c)
+	if err != nil {
+		panic(fmt.Sprintf("encode cursor: %v", err))
+	}
+	return base64.URLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	data, err := base64.URLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decode cursor: %w", err)
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("decode cursor: %w", err)
+	}
+
+	return &c, nil
+}
+
+// buildGetBooksFilter turns q's filters into a WHERE clause shared by the
+// count query and the page query, so Total reflects the same rows the page
+// is drawn from.
+func buildGetBooksFilter(q library.GetBooksQuery) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if q.TitleContains != "" {
+		conditions = append(conditions, fmt.Sprintf("b.title ILIKE $%d", len(args)+1))
+		args = append(args, "%"+q.TitleContains+"%")
+	}
+
+	if q.AuthorEquals != "" {
+		idx := len(args) + 1
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (
+			SELECT 1
+			FROM books_authors ba2
+			JOIN authors a2 ON a2.id = ba2.author_id
+			WHERE ba2.book_id = b.id
+			  AND (a2.forename = $%d OR a2.lastname = $%d OR a2.forename || ' ' || a2.lastname = $%d)
+		)`, idx, idx, idx))
+		args = append(args, q.AuthorEquals)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// buildGetBooksQuery builds the page query for GetBooks: the sort key
+// expression depends on q.SortBy, and when cur is non-nil a keyset
+// predicate on (sort key, id) is appended so the page picks up where the
+// last one left off.
+//
+// The per-book sort key is computed in an inner, grouped subquery and the
+// keyset predicate is applied in the outer query against that subquery's
+// plain sort_key column, rather than against the aggregate expression
+// directly — Postgres doesn't allow aggregates in a WHERE clause, only in
+// SELECT/HAVING once the rows they're computed from are already grouped.
+func buildGetBooksQuery(q library.GetBooksQuery, cur *cursor, whereSQL string, whereArgs []interface{}) (string, []interface{}) {
+	dir, cmp := "ASC", ">"
+	if q.SortDesc {
+		dir, cmp = "DESC", "<"
+	}
+
+	sortBy := q.SortBy
+	if sortBy == "" {
+		sortBy = library.SortByID
+	}
+
+	var sortExpr string
+	switch sortBy {
+	case library.SortByTitle:
+		sortExpr = "b.title"
+	case library.SortByAuthor:
+		sortExpr = "coalesce(min(a.lastname), '')"
+	default:
+		sortExpr = "null::text"
+	}
+
+	args := append([]interface{}{}, whereArgs...)
+
+	innerWhere := ""
+	if whereSQL != "" {
+		innerWhere = whereSQL
+	}
+
+	outerConditions := []string{}
+	if cur != nil {
+		if sortBy == library.SortByID {
+			outerConditions = append(outerConditions, fmt.Sprintf("id %s $%d", cmp, len(args)+1))
+			args = append(args, cur.LastID)
+		} else {
+			outerConditions = append(outerConditions, fmt.Sprintf("(sort_key, id) %s ($%d, $%d)", cmp, len(args)+1, len(args)+2))
+			args = append(args, cur.LastKey, cur.LastID)
+		}
+	}
+
+	outerWhere := ""
+	if len(outerConditions) > 0 {
+		outerWhere = "WHERE " + strings.Join(outerConditions, " AND ")
+	}
+
+	orderBy := fmt.Sprintf("ORDER BY id %s", dir)
+	if sortBy != library.SortByID {
+		orderBy = fmt.Sprintf("ORDER BY sort_key %s, id %s", dir, dir)
+	}
+
+	limitSQL := ""
+	if q.Limit > 0 {
+		limitSQL = fmt.Sprintf("LIMIT $%d", len(args)+1)
+		args = append(args, q.Limit+1)
+	}
+
+	sql := fmt.Sprintf(`
+		SELECT id, title, sort_key
+		FROM (
+			SELECT b.id, b.title, %s AS sort_key
+			FROM books b
+			LEFT JOIN books_authors ba ON ba.book_id = b.id
+			LEFT JOIN authors a ON a.id = ba.author_id
+			%s
+			GROUP BY b.id
+		) book_sort
+		%s
+		%s
+		%s;
+	`, sortExpr, innerWhere, outerWhere, orderBy, limitSQL)
+
+	return sql, args
+}
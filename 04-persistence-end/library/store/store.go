@@ -2,57 +2,562 @@ package store
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
 
 	"github.com/jmoiron/sqlx"
 
 	"github.com/haleyrc/bookshelf/library"
+	"github.com/haleyrc/bookshelf/library/events"
 )
 
 type LibraryStore struct {
-	DB *sqlx.DB
+	DB     *sqlx.DB
+	Events *events.EventStore
 }
 
-func (s *LibraryStore) CreateBook(ctx context.Context, book *library.Book) error {
-	q := `INSERT INTO books (title, author) VALUES ($1, $2) RETURNING id;`
+func (s *LibraryStore) BeginTx(ctx context.Context) (library.Transaction, error) {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+
+	return &LibraryTx{tx: tx, events: s.Events}, nil
+}
 
-	err := s.DB.GetContext(ctx, &book.ID, q, book.Title, book.Author)
+func (s *LibraryStore) GetBookHistory(ctx context.Context, bookID int64) ([]library.BookEvent, error) {
+	history, err := s.Events.GetBookHistory(ctx, bookID)
 	if err != nil {
-		return fmt.Errorf("create book: %w", err)
+		return nil, fmt.Errorf("get book history: %w", err)
+	}
+	return history, nil
+}
+
+func (s *LibraryStore) CreateBookFile(ctx context.Context, file *library.BookFile) error {
+	if file.Kind == "" {
+		file.Kind = "book"
+	}
+
+	q := `
+		INSERT INTO book_files (book_id, kind, path, format, object_key, content_type, size)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created;
+	`
+
+	err := s.DB.QueryRowxContext(
+		ctx, q,
+		file.BookID, file.Kind, file.Path, file.Format, nullify(file.ObjectKey), nullify(file.ContentType), file.Size,
+	).Scan(&file.ID, &file.Created)
+	if err != nil {
+		return fmt.Errorf("create book file: %w", err)
 	}
 
 	return nil
 }
 
-func (s *LibraryStore) GetBookByID(ctx context.Context, id int64) (*library.Book, error) {
-	q := `SELECT id, title, author FROM books WHERE id = $1;`
+func (s *LibraryStore) GetBookFilesForBook(ctx context.Context, bookID int64) ([]*library.BookFile, error) {
+	q := `
+		SELECT id, book_id, kind, path, format, object_key, content_type, size, created
+		FROM book_files
+		WHERE book_id = $1
+		ORDER BY id ASC;
+	`
 
-	var book library.Book
-	err := s.DB.QueryRowxContext(ctx, q, id).Scan(&book.ID, &book.Title, &book.Author)
+	rows, err := s.DB.QueryxContext(ctx, q, bookID)
 	if err != nil {
-		return nil, fmt.Errorf("get book by id: %w", err)
+		return nil, fmt.Errorf("get book files for book: %w", err)
 	}
+	defer rows.Close()
 
-	return &book, nil
+	files := []*library.BookFile{}
+	for rows.Next() {
+		var (
+			file        library.BookFile
+			objectKey   sql.NullString
+			contentType sql.NullString
+		)
+		err := rows.Scan(
+			&file.ID, &file.BookID, &file.Kind, &file.Path, &file.Format,
+			&objectKey, &contentType, &file.Size, &file.Created,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("get book files for book: %w", err)
+		}
+		file.ObjectKey = objectKey.String
+		file.ContentType = contentType.String
+		files = append(files, &file)
+	}
+
+	return files, nil
 }
 
-func (s *LibraryStore) GetBooks(ctx context.Context) ([]*library.Book, error) {
-	q := `SELECT id, title, author FROM books ORDER BY id ASC;`
+// nullify turns an empty string into a SQL NULL so optional text columns
+// don't end up storing "" instead of NULL.
+func nullify(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func (s *LibraryStore) GetAuthorByID(ctx context.Context, id int64) (*library.Author, error) {
+	q := `SELECT id, forename, lastname, created, updated FROM authors WHERE id = $1;`
+
+	var author library.Author
+	err := s.DB.QueryRowxContext(ctx, q, id).Scan(&author.ID, &author.Forename, &author.Lastname, &author.Created, &author.Updated)
+	if err != nil {
+		return nil, fmt.Errorf("get author by id: %w", err)
+	}
+
+	return &author, nil
+}
+
+func (s *LibraryStore) GetAuthors(ctx context.Context) ([]*library.Author, error) {
+	q := `SELECT id, forename, lastname, created, updated FROM authors ORDER BY id ASC;`
 
 	rows, err := s.DB.QueryxContext(ctx, q)
 	if err != nil {
-		return nil, fmt.Errorf("get books: %w", err)
+		return nil, fmt.Errorf("get authors: %w", err)
+	}
+	defer rows.Close()
+
+	authors := []*library.Author{}
+	for rows.Next() {
+		var author library.Author
+		if err := rows.Scan(&author.ID, &author.Forename, &author.Lastname, &author.Created, &author.Updated); err != nil {
+			return nil, fmt.Errorf("get authors: %w", err)
+		}
+		authors = append(authors, &author)
+	}
+
+	return authors, nil
+}
+
+func (s *LibraryStore) GetAuthorsForBook(ctx context.Context, bookID int64) ([]*library.Author, error) {
+	q := `
+		SELECT a.id, a.forename, a.lastname, a.created, a.updated
+		FROM authors a
+		JOIN books_authors ba ON ba.author_id = a.id
+		WHERE ba.book_id = $1
+		ORDER BY a.id ASC;
+	`
+
+	rows, err := s.DB.QueryxContext(ctx, q, bookID)
+	if err != nil {
+		return nil, fmt.Errorf("get authors for book: %w", err)
+	}
+	defer rows.Close()
+
+	authors := []*library.Author{}
+	for rows.Next() {
+		var author library.Author
+		if err := rows.Scan(&author.ID, &author.Forename, &author.Lastname, &author.Created, &author.Updated); err != nil {
+			return nil, fmt.Errorf("get authors for book: %w", err)
+		}
+		authors = append(authors, &author)
+	}
+
+	return authors, nil
+}
+
+func (s *LibraryStore) GetBooksForAuthor(ctx context.Context, authorID int64) ([]*library.Book, error) {
+	q := `
+		SELECT b.id, b.title
+		FROM books b
+		JOIN books_authors ba ON ba.book_id = b.id
+		WHERE ba.author_id = $1
+		ORDER BY b.id ASC;
+	`
+
+	rows, err := s.DB.QueryxContext(ctx, q, authorID)
+	if err != nil {
+		return nil, fmt.Errorf("get books for author: %w", err)
 	}
 	defer rows.Close()
 
 	books := []*library.Book{}
 	for rows.Next() {
 		var book library.Book
-		if err := rows.Scan(&book.ID, &book.Title, &book.Author); err != nil {
-			return nil, fmt.Errorf("get books: %w", err)
+		if err := rows.Scan(&book.ID, &book.Title); err != nil {
+			return nil, fmt.Errorf("get books for author: %w", err)
 		}
 		books = append(books, &book)
 	}
 
 	return books, nil
 }
+
+func (s *LibraryStore) GetBookByID(ctx context.Context, id int64) (*library.Book, error) {
+	q := `SELECT id, title FROM books WHERE id = $1;`
+
+	var book library.Book
+	if err := s.DB.QueryRowxContext(ctx, q, id).Scan(&book.ID, &book.Title); err != nil {
+		return nil, fmt.Errorf("get book by id: %w", err)
+	}
+
+	if err := s.hydrateBook(ctx, &book); err != nil {
+		return nil, fmt.Errorf("get book by id: %w", err)
+	}
+
+	return &book, nil
+}
+
+// hydrateBook fills in book's authors, tags, publisher, and series.
+func (s *LibraryStore) hydrateBook(ctx context.Context, book *library.Book) error {
+	authors, err := s.GetAuthorsForBook(ctx, book.ID)
+	if err != nil {
+		return err
+	}
+	book.Authors = dereferenceAuthors(authors)
+
+	tags, err := s.GetTagsForBook(ctx, book.ID)
+	if err != nil {
+		return err
+	}
+	book.Tags = tags
+
+	publisher, err := s.GetPublisherForBook(ctx, book.ID)
+	if err != nil {
+		return err
+	}
+	book.Publisher = publisher
+
+	series, index, err := s.GetSeriesForBook(ctx, book.ID)
+	if err != nil {
+		return err
+	}
+	book.Series = series
+	book.SeriesIndex = index
+
+	return nil
+}
+
+func (s *LibraryStore) GetTagsForBook(ctx context.Context, bookID int64) ([]library.Tag, error) {
+	q := `
+		SELECT t.id, t.name
+		FROM tags t
+		JOIN books_tags bt ON bt.tag_id = t.id
+		WHERE bt.book_id = $1
+		ORDER BY t.name ASC;
+	`
+
+	rows, err := s.DB.QueryxContext(ctx, q, bookID)
+	if err != nil {
+		return nil, fmt.Errorf("get tags for book: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []library.Tag{}
+	for rows.Next() {
+		var tag library.Tag
+		if err := rows.Scan(&tag.ID, &tag.Name); err != nil {
+			return nil, fmt.Errorf("get tags for book: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+func (s *LibraryStore) GetPublisherForBook(ctx context.Context, bookID int64) (*library.Publisher, error) {
+	q := `
+		SELECT p.id, p.name
+		FROM publishers p
+		JOIN books_publisher bp ON bp.publisher_id = p.id
+		WHERE bp.book_id = $1;
+	`
+
+	var publisher library.Publisher
+	err := s.DB.QueryRowxContext(ctx, q, bookID).Scan(&publisher.ID, &publisher.Name)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get publisher for book: %w", err)
+	}
+
+	return &publisher, nil
+}
+
+func (s *LibraryStore) GetSeriesForBook(ctx context.Context, bookID int64) (*library.Series, *float64, error) {
+	q := `
+		SELECT s.id, s.name, bs.series_index
+		FROM series s
+		JOIN books_series bs ON bs.series_id = s.id
+		WHERE bs.book_id = $1;
+	`
+
+	var (
+		series library.Series
+		index  sql.NullFloat64
+	)
+	err := s.DB.QueryRowxContext(ctx, q, bookID).Scan(&series.ID, &series.Name, &index)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("get series for book: %w", err)
+	}
+
+	var indexPtr *float64
+	if index.Valid {
+		indexPtr = &index.Float64
+	}
+
+	return &series, indexPtr, nil
+}
+
+// GetBooks returns one page of books matching q, using keyset pagination
+// (a WHERE on the last row's sort key, rather than OFFSET) so performance
+// stays flat as the table grows. See pagination.go for the query building.
+func (s *LibraryStore) GetBooks(ctx context.Context, q library.GetBooksQuery) (library.GetBooksResult, error) {
+	cur, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return library.GetBooksResult{}, fmt.Errorf("get books: %w", err)
+	}
+
+	whereSQL, whereArgs := buildGetBooksFilter(q)
+
+	total, err := s.countBooks(ctx, whereSQL, whereArgs)
+	if err != nil {
+		return library.GetBooksResult{}, fmt.Errorf("get books: %w", err)
+	}
+
+	querySQL, args := buildGetBooksQuery(q, cur, whereSQL, whereArgs)
+
+	rows, err := s.DB.QueryxContext(ctx, querySQL, args...)
+	if err != nil {
+		return library.GetBooksResult{}, fmt.Errorf("get books: %w", err)
+	}
+
+	type row struct {
+		ID      int64
+		Title   string
+		SortKey sql.NullString
+	}
+	var matched []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.ID, &r.Title, &r.SortKey); err != nil {
+			rows.Close()
+			return library.GetBooksResult{}, fmt.Errorf("get books: %w", err)
+		}
+		matched = append(matched, r)
+	}
+	rows.Close()
+
+	limit := q.Limit
+	hasMore := limit > 0 && len(matched) > limit
+	if hasMore {
+		matched = matched[:limit]
+	}
+
+	books := make([]*library.Book, len(matched))
+	for i, r := range matched {
+		books[i] = &library.Book{ID: r.ID, Title: r.Title}
+	}
+	for _, book := range books {
+		if err := s.hydrateBook(ctx, book); err != nil {
+			return library.GetBooksResult{}, fmt.Errorf("get books: %w", err)
+		}
+	}
+
+	result := library.GetBooksResult{Books: books, Total: total}
+	if hasMore {
+		last := matched[len(matched)-1]
+		result.NextCursor = encodeCursor(cursor{LastID: last.ID, LastKey: last.SortKey.String})
+	}
+
+	return result, nil
+}
+
+func (s *LibraryStore) countBooks(ctx context.Context, whereSQL string, whereArgs []interface{}) (int64, error) {
+	q := fmt.Sprintf(`
+		SELECT count(DISTINCT b.id)
+		FROM books b
+		LEFT JOIN books_authors ba ON ba.book_id = b.id
+		LEFT JOIN authors a ON a.id = ba.author_id
+		%s;
+	`, whereSQL)
+
+	var total int64
+	if err := s.DB.QueryRowxContext(ctx, q, whereArgs...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("count books: %w", err)
+	}
+	return total, nil
+}
+
+func dereferenceAuthors(authors []*library.Author) []library.Author {
+	out := make([]library.Author, len(authors))
+	for i, author := range authors {
+		out[i] = *author
+	}
+	return out
+}
+
+// LibraryTx implements library.Transaction against a single Postgres
+// transaction so LibraryService can create a book, upsert its author, link
+// the two, and log the CREATED event all atomically.
+type LibraryTx struct {
+	tx     *sqlx.Tx
+	events *events.EventStore
+}
+
+func (t *LibraryTx) CreateBook(ctx context.Context, book *library.Book) error {
+	q := `INSERT INTO books (title) VALUES ($1) RETURNING id;`
+
+	if err := t.tx.GetContext(ctx, &book.ID, q, book.Title); err != nil {
+		return fmt.Errorf("create book: %w", err)
+	}
+
+	payload, err := json.Marshal(struct {
+		Title string `json:"title"`
+	}{Title: book.Title})
+	if err != nil {
+		return fmt.Errorf("create book: %w", err)
+	}
+
+	event := library.BookEvent{BookID: book.ID, Type: events.Created, Payload: payload}
+	if err := t.events.Record(ctx, t.tx, &event); err != nil {
+		return fmt.Errorf("create book: %w", err)
+	}
+
+	return nil
+}
+
+// CreateAuthor upserts author by name: if an author with the same forename
+// and lastname already exists, author is populated from that row instead of
+// inserting a duplicate. The authors_forename_lastname_idx unique index
+// backs the ON CONFLICT, so this is safe under concurrent callers racing to
+// create the same new author, unlike a check-then-act SELECT then INSERT.
+func (t *LibraryTx) CreateAuthor(ctx context.Context, author *library.Author) error {
+	q := `
+		INSERT INTO authors (forename, lastname)
+		VALUES ($1, $2)
+		ON CONFLICT (forename, lastname) DO UPDATE SET updated = now()
+		RETURNING id, created, updated;
+	`
+
+	err := t.tx.QueryRowxContext(ctx, q, author.Forename, author.Lastname).
+		Scan(&author.ID, &author.Created, &author.Updated)
+	if err != nil {
+		return fmt.Errorf("create author: %w", err)
+	}
+
+	return nil
+}
+
+func (t *LibraryTx) AttachAuthor(ctx context.Context, bookID, authorID int64) error {
+	q := `INSERT INTO books_authors (book_id, author_id) VALUES ($1, $2);`
+
+	if _, err := t.tx.ExecContext(ctx, q, bookID, authorID); err != nil {
+		return fmt.Errorf("attach author: %w", err)
+	}
+
+	return nil
+}
+
+// CreateTag upserts tag by name against the tags_name_idx unique index, the
+// same pattern CreateAuthor uses for forename/lastname.
+func (t *LibraryTx) CreateTag(ctx context.Context, tag *library.Tag) error {
+	q := `
+		INSERT INTO tags (name)
+		VALUES ($1)
+		ON CONFLICT (name) DO UPDATE SET name = excluded.name
+		RETURNING id;
+	`
+
+	if err := t.tx.QueryRowxContext(ctx, q, tag.Name).Scan(&tag.ID); err != nil {
+		return fmt.Errorf("create tag: %w", err)
+	}
+
+	return nil
+}
+
+func (t *LibraryTx) AttachTag(ctx context.Context, bookID, tagID int64) error {
+	q := `INSERT INTO books_tags (book_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING;`
+
+	if _, err := t.tx.ExecContext(ctx, q, bookID, tagID); err != nil {
+		return fmt.Errorf("attach tag: %w", err)
+	}
+
+	return nil
+}
+
+// CreatePublisher upserts publisher by name against the
+// publishers_name_idx unique index.
+func (t *LibraryTx) CreatePublisher(ctx context.Context, publisher *library.Publisher) error {
+	q := `
+		INSERT INTO publishers (name)
+		VALUES ($1)
+		ON CONFLICT (name) DO UPDATE SET name = excluded.name
+		RETURNING id;
+	`
+
+	if err := t.tx.QueryRowxContext(ctx, q, publisher.Name).Scan(&publisher.ID); err != nil {
+		return fmt.Errorf("create publisher: %w", err)
+	}
+
+	return nil
+}
+
+// AttachPublisher replaces whatever publisher book had, since a book has at
+// most one.
+func (t *LibraryTx) AttachPublisher(ctx context.Context, bookID, publisherID int64) error {
+	q := `
+		INSERT INTO books_publisher (book_id, publisher_id)
+		VALUES ($1, $2)
+		ON CONFLICT (book_id) DO UPDATE SET publisher_id = excluded.publisher_id;
+	`
+
+	if _, err := t.tx.ExecContext(ctx, q, bookID, publisherID); err != nil {
+		return fmt.Errorf("attach publisher: %w", err)
+	}
+
+	return nil
+}
+
+// CreateSeries upserts series by name against the series_name_idx unique
+// index.
+func (t *LibraryTx) CreateSeries(ctx context.Context, series *library.Series) error {
+	q := `
+		INSERT INTO series (name)
+		VALUES ($1)
+		ON CONFLICT (name) DO UPDATE SET name = excluded.name
+		RETURNING id;
+	`
+
+	if err := t.tx.QueryRowxContext(ctx, q, series.Name).Scan(&series.ID); err != nil {
+		return fmt.Errorf("create series: %w", err)
+	}
+
+	return nil
+}
+
+// AttachSeries replaces whatever series book had, since a book belongs to
+// at most one series.
+func (t *LibraryTx) AttachSeries(ctx context.Context, bookID, seriesID int64, index *float64) error {
+	q := `
+		INSERT INTO books_series (book_id, series_id, series_index)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (book_id) DO UPDATE SET series_id = excluded.series_id, series_index = excluded.series_index;
+	`
+
+	if _, err := t.tx.ExecContext(ctx, q, bookID, seriesID, index); err != nil {
+		return fmt.Errorf("attach series: %w", err)
+	}
+
+	return nil
+}
+
+func (t *LibraryTx) Commit() error {
+	if err := t.tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+func (t *LibraryTx) Rollback() error {
+	if err := t.tx.Rollback(); err != nil {
+		return fmt.Errorf("rollback tx: %w", err)
+	}
+	return nil
+}
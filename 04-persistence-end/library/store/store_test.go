@@ -13,6 +13,7 @@ import (
 
 	"github.com/haleyrc/bookshelf/internal/test"
 	"github.com/haleyrc/bookshelf/library"
+	"github.com/haleyrc/bookshelf/library/events"
 	"github.com/haleyrc/bookshelf/library/store"
 )
 
@@ -28,6 +29,7 @@ func TestMain(m *testing.M) {
 		os.Exit(0)
 	}
 	ls.DB = sqlx.MustConnect("postgres", url)
+	ls.Events = &events.EventStore{DB: ls.DB}
 
 	code := m.Run()
 
@@ -35,44 +37,66 @@ func TestMain(m *testing.M) {
 	os.Exit(code)
 }
 
-func TestLibraryStore_CreateBook(t *testing.T) {
+func TestLibraryStore_CreateBookAndAuthor(t *testing.T) {
 	ctx := context.Background()
 
-	book := library.Book{
-		Title:  "The Lean Startup",
-		Author: "Eric Ries",
+	tx, err := ls.BeginTx(ctx)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
 	}
-	if err := ls.CreateBook(ctx, &book); err != nil {
+
+	book := library.Book{Title: "The Lean Startup"}
+	if err := tx.CreateBook(ctx, &book); err != nil {
 		t.Fatal("unexpected error:", err)
 	}
 	test.MustCleanup(t, func() error {
-		return deleteBook(ctx, t, ls.DB, book.ID)
+		return deleteRow(ctx, ls.DB, "books", book.ID)
 	})
 
+	author := library.Author{Forename: "Eric", Lastname: "Ries"}
+	if err := tx.CreateAuthor(ctx, &author); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	test.MustCleanup(t, func() error {
+		return deleteRow(ctx, ls.DB, "authors", author.ID)
+	})
+
+	if err := tx.AttachAuthor(ctx, book.ID, author.ID); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
 	if book.ID == 0 {
-		t.Errorf("expected id to not be blank, but it was")
+		t.Errorf("expected book id to not be blank, but it was")
 	}
-	if book.Title != "The Lean Startup" {
-		t.Errorf("expected title to be \"The Lean Startup\" but got %q", book.Title)
+	if author.ID == 0 {
+		t.Errorf("expected author id to not be blank, but it was")
 	}
-	if book.Author != "Eric Ries" {
-		t.Errorf("expected author to be \"Eric Ries\" but got %q", book.Author)
+	if author.Created.IsZero() {
+		t.Errorf("expected author created timestamp to be set, but it was zero")
 	}
 }
 
-func TestLibraryStore_GetBookByID(t *testing.T) {
+func TestLibraryStore_CreateAuthorReusesExistingAuthorByName(t *testing.T) {
 	ctx := context.Background()
 
-	book := library.Book{
-		Title:  "The Lean Startup",
-		Author: "Eric Ries",
+	book1, author1 := createBookAndAuthor(ctx, t, "Good Omens", "Terry", "Pratchett")
+	book2, author2 := createBookAndAuthor(ctx, t, "Mort", "Terry", "Pratchett")
+
+	if author1.ID != author2.ID {
+		t.Errorf("expected both books to share author id %d, but got %d and %d", author1.ID, author1.ID, author2.ID)
 	}
-	if err := ls.CreateBook(ctx, &book); err != nil {
-		t.Fatal("unexpected error:", err)
+	if book1.ID == book2.ID {
+		t.Fatalf("expected distinct book ids, but both were %d", book1.ID)
 	}
-	test.MustCleanup(t, func() error {
-		return deleteBook(ctx, t, ls.DB, book.ID)
-	})
+}
+
+func TestLibraryStore_GetBookByID(t *testing.T) {
+	ctx := context.Background()
+
+	book, author := createBookAndAuthor(ctx, t, "The Lean Startup", "Eric", "Ries")
 
 	gotBook, err := ls.GetBookByID(ctx, book.ID)
 	if err != nil {
@@ -84,65 +108,381 @@ func TestLibraryStore_GetBookByID(t *testing.T) {
 	if gotBook.Title != "The Lean Startup" {
 		t.Errorf("expected title to be \"The Lean Startup\" but got %q", gotBook.Title)
 	}
-	if gotBook.Author != "Eric Ries" {
-		t.Errorf("expected author to be \"Eric Ries\" but got %q", gotBook.Author)
+	if len(gotBook.Authors) != 1 {
+		t.Fatalf("expected exactly one author, but got %d", len(gotBook.Authors))
+	}
+	if gotBook.Authors[0].ID != author.ID {
+		t.Errorf("expected author id to be %d but got %d", author.ID, gotBook.Authors[0].ID)
 	}
 }
 
-func TestLibraryStore_GetBooks(t *testing.T) {
+func TestLibraryStore_GetBookByIDIncludesTagsPublisherAndSeries(t *testing.T) {
 	ctx := context.Background()
 
-	params := [][]string{
-		{"Norse Mythology", "Neil Gaiman"},
-		{"The Divine Comedy", "Dante Alighieri"},
-		{"2001: A Space Odyssey", "Arthur C. Clarke"},
+	book, _ := createBookAndAuthor(ctx, t, "Good Omens", "Terry", "Pratchett")
+
+	tx, err := ls.BeginTx(ctx)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
 	}
-	ids, err := createManyBooks(ctx, t, ls, params)
+
+	tag := library.Tag{Name: "Fantasy"}
+	if err := tx.CreateTag(ctx, &tag); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	test.MustCleanup(t, func() error {
+		return deleteRow(ctx, ls.DB, "tags", tag.ID)
+	})
+	if err := tx.AttachTag(ctx, book.ID, tag.ID); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	publisher := library.Publisher{Name: "Gollancz"}
+	if err := tx.CreatePublisher(ctx, &publisher); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	test.MustCleanup(t, func() error {
+		return deleteRow(ctx, ls.DB, "publishers", publisher.ID)
+	})
+	if err := tx.AttachPublisher(ctx, book.ID, publisher.ID); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	series := library.Series{Name: "Discworld"}
+	if err := tx.CreateSeries(ctx, &series); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	test.MustCleanup(t, func() error {
+		return deleteRow(ctx, ls.DB, "series", series.ID)
+	})
+	index := 27.0
+	if err := tx.AttachSeries(ctx, book.ID, series.ID, &index); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	gotBook, err := ls.GetBookByID(ctx, book.ID)
 	if err != nil {
 		t.Fatal("unexpected error:", err)
 	}
 
-	books, err := ls.GetBooks(ctx)
+	if len(gotBook.Tags) != 1 || gotBook.Tags[0].ID != tag.ID {
+		t.Errorf("expected tags %v, but got %v", []library.Tag{tag}, gotBook.Tags)
+	}
+	if gotBook.Publisher == nil || gotBook.Publisher.ID != publisher.ID {
+		t.Errorf("expected publisher %v, but got %v", publisher, gotBook.Publisher)
+	}
+	if gotBook.Series == nil || gotBook.Series.ID != series.ID {
+		t.Errorf("expected series %v, but got %v", series, gotBook.Series)
+	}
+	if gotBook.SeriesIndex == nil || *gotBook.SeriesIndex != index {
+		t.Errorf("expected series index %v, but got %v", index, gotBook.SeriesIndex)
+	}
+}
+
+func TestLibraryStore_GetBooks(t *testing.T) {
+	ctx := context.Background()
+
+	params := [][3]string{
+		{"Norse Mythology", "Neil", "Gaiman"},
+		{"The Divine Comedy", "Dante", "Alighieri"},
+		{"2001: A Space Odyssey", "Arthur", "C. Clarke"},
+	}
+
+	bookIDs := []int64{}
+	for _, p := range params {
+		book, _ := createBookAndAuthor(ctx, t, p[0], p[1], p[2])
+		bookIDs = append(bookIDs, book.ID)
+	}
+
+	result, err := ls.GetBooks(ctx, library.GetBooksQuery{})
 	if err != nil {
 		t.Fatal("unexpected error:", err)
 	}
 
-	for idx, book := range books {
-		id := ids[idx]
+	for idx, id := range bookIDs {
 		title := params[idx][0]
-		author := params[idx][1]
 
-		if book.ID != id {
-			t.Errorf("expected book %d id to be %d, but got %d", idx, id, book.ID)
+		book := findBook(result.Books, id)
+		if book == nil {
+			t.Fatalf("expected book %d to be in the results, but it wasn't", id)
 		}
 		if book.Title != title {
 			t.Errorf("expected book %d title to be %q, but got %q", idx, title, book.Title)
 		}
-		if book.Author != author {
-			t.Errorf("expected book %d author to be %q, but got %q", idx, author, book.Author)
+		if len(book.Authors) != 1 {
+			t.Errorf("expected book %d to have exactly one author, but got %d", idx, len(book.Authors))
 		}
 	}
 }
 
-func createManyBooks(ctx context.Context, t *testing.T, ls store.LibraryStore, params [][]string) ([]int64, error) {
-	ids := []int64{}
+func TestLibraryStore_GetBooksFiltersAndSorts(t *testing.T) {
+	ctx := context.Background()
+
+	createBookAndAuthor(ctx, t, "Norse Mythology", "Neil", "Gaiman")
+	createBookAndAuthor(ctx, t, "American Gods", "Neil", "Gaiman")
+	createBookAndAuthor(ctx, t, "The Divine Comedy", "Dante", "Alighieri")
+
+	result, err := ls.GetBooks(ctx, library.GetBooksQuery{AuthorEquals: "Gaiman"})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(result.Books) != 2 {
+		t.Fatalf("expected exactly 2 books by Gaiman, but got %d", len(result.Books))
+	}
+
+	result, err = ls.GetBooks(ctx, library.GetBooksQuery{TitleContains: "Divine"})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(result.Books) != 1 || result.Books[0].Title != "The Divine Comedy" {
+		t.Fatalf("expected exactly \"The Divine Comedy\", but got %+v", result.Books)
+	}
+}
+
+func TestLibraryStore_GetBooksSortByAuthorPastFirstPage(t *testing.T) {
+	ctx := context.Background()
+
+	params := [][3]string{
+		{"The Divine Comedy", "Dante", "Alighieri"},
+		{"Good Omens", "Terry", "Pratchett"},
+		{"Norse Mythology", "Neil", "Gaiman"},
+		{"2001: A Space Odyssey", "Arthur", "C. Clarke"},
+	}
 	for _, p := range params {
-		b := library.Book{Title: p[0], Author: p[1]}
-		if err := ls.CreateBook(ctx, &b); err != nil {
-			return nil, err
-		}
-		test.MustCleanup(t, func() error {
-			return deleteBook(ctx, t, ls.DB, b.ID)
+		createBookAndAuthor(ctx, t, p[0], p[1], p[2])
+	}
+
+	var titles []string
+	cursor := ""
+	for {
+		result, err := ls.GetBooks(ctx, library.GetBooksQuery{
+			Limit:  2,
+			Cursor: cursor,
+			SortBy: library.SortByAuthor,
 		})
-		ids = append(ids, b.ID)
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+		for _, book := range result.Books {
+			titles = append(titles, book.Title)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		cursor = result.NextCursor
+	}
+
+	want := []string{"The Divine Comedy", "2001: A Space Odyssey", "Norse Mythology", "Good Omens"}
+	if len(titles) != len(want) {
+		t.Fatalf("expected %d titles across all pages, but got %d: %v", len(want), len(titles), titles)
+	}
+	for i, title := range want {
+		if titles[i] != title {
+			t.Errorf("expected title %d to be %q, but got %q", i, title, titles[i])
+		}
+	}
+}
+
+func TestLibraryStore_GetBooksCursorStabilityAcrossInserts(t *testing.T) {
+	ctx := context.Background()
+
+	const seeded = 300
+	for i := 0; i < seeded; i++ {
+		createBookAndAuthor(ctx, t, fmt.Sprintf("Seed Book %04d", i), "Seed", "Author")
+	}
+
+	const pageSize = 25
+
+	seen := map[int64]bool{}
+	var pages int
+
+	cursor := ""
+	for {
+		result, err := ls.GetBooks(ctx, library.GetBooksQuery{Limit: pageSize, Cursor: cursor})
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+		for _, book := range result.Books {
+			if seen[book.ID] {
+				t.Fatalf("book %d appeared on more than one page", book.ID)
+			}
+			seen[book.ID] = true
+		}
+
+		pages++
+		if pages > 1 {
+			// A book inserted mid-walk should never reshuffle already-seen
+			// pages, since keyset pagination only looks forward from the
+			// cursor.
+			createBookAndAuthor(ctx, t, fmt.Sprintf("Inserted During Walk %d", pages), "Seed", "Author")
+		}
+
+		if result.NextCursor == "" {
+			break
+		}
+		cursor = result.NextCursor
+
+		if pages > seeded {
+			t.Fatal("paginated more times than there are pages; cursor isn't advancing")
+		}
+	}
+
+	if int64(len(seen)) < seeded {
+		t.Errorf("expected to see at least %d seeded books, but got %d", seeded, len(seen))
 	}
-	return ids, nil
 }
 
-func deleteBook(ctx context.Context, t *testing.T, db *sqlx.DB, id int64) error {
-	q := `DELETE FROM books WHERE id = $1;`
+func TestLibraryStore_CreateBookRecordsCreatedEvent(t *testing.T) {
+	ctx := context.Background()
+
+	book, _ := createBookAndAuthor(ctx, t, "The Lean Startup", "Eric", "Ries")
+
+	history, err := ls.GetBookHistory(ctx, book.ID)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("expected exactly one event, but got %d", len(history))
+	}
+	if history[0].Type != events.Created {
+		t.Errorf("expected event type %q, but got %q", events.Created, history[0].Type)
+	}
+}
+
+func TestLibraryStore_CreateBookEventIsRolledBackWithBook(t *testing.T) {
+	ctx := context.Background()
+
+	tx, err := ls.BeginTx(ctx)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	book := library.Book{Title: "Rolled Back"}
+	if err := tx.CreateBook(ctx, &book); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if _, err := ls.GetBookByID(ctx, book.ID); err == nil {
+		t.Errorf("expected rolled back book to be gone, but it was found")
+	}
+
+	history, err := ls.GetBookHistory(ctx, book.ID)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(history) != 0 {
+		t.Errorf("expected no events for a rolled back book, but got %d", len(history))
+	}
+}
+
+func TestLibraryStore_CreateBookFileAndGetBookFilesForBook(t *testing.T) {
+	ctx := context.Background()
+
+	book, _ := createBookAndAuthor(ctx, t, "The Lean Startup", "Eric", "Ries")
+
+	file := library.BookFile{BookID: book.ID, Path: "books/1/lean-startup.epub", Format: "epub", Size: 1024}
+	if err := ls.CreateBookFile(ctx, &file); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	test.MustCleanup(t, func() error {
+		return deleteRow(ctx, ls.DB, "book_files", file.ID)
+	})
+
+	if file.ID == 0 {
+		t.Errorf("expected book file id to not be blank, but it was")
+	}
+	if file.Created.IsZero() {
+		t.Errorf("expected book file created timestamp to be set, but it was zero")
+	}
+
+	files, err := ls.GetBookFilesForBook(ctx, book.ID)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(files) != 1 || files[0].ID != file.ID {
+		t.Fatalf("expected exactly file %d, but got %+v", file.ID, files)
+	}
+	if files[0].Format != "epub" {
+		t.Errorf("expected format %q, but got %q", "epub", files[0].Format)
+	}
+}
+
+func TestLibraryStore_GetAuthorsForBookAndGetBooksForAuthor(t *testing.T) {
+	ctx := context.Background()
+
+	book, author := createBookAndAuthor(ctx, t, "Good Omens", "Terry", "Pratchett")
+
+	authors, err := ls.GetAuthorsForBook(ctx, book.ID)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(authors) != 1 || authors[0].ID != author.ID {
+		t.Fatalf("expected exactly author %d, but got %+v", author.ID, authors)
+	}
+
+	books, err := ls.GetBooksForAuthor(ctx, author.ID)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(books) != 1 || books[0].ID != book.ID {
+		t.Fatalf("expected exactly book %d, but got %+v", book.ID, books)
+	}
+}
+
+func createBookAndAuthor(ctx context.Context, t *testing.T, title, forename, lastname string) (*library.Book, *library.Author) {
+	t.Helper()
+
+	tx, err := ls.BeginTx(ctx)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	book := &library.Book{Title: title}
+	if err := tx.CreateBook(ctx, book); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	test.MustCleanup(t, func() error {
+		return deleteRow(ctx, ls.DB, "books", book.ID)
+	})
+
+	author := &library.Author{Forename: forename, Lastname: lastname}
+	if err := tx.CreateAuthor(ctx, author); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	test.MustCleanup(t, func() error {
+		return deleteRow(ctx, ls.DB, "authors", author.ID)
+	})
+
+	if err := tx.AttachAuthor(ctx, book.ID, author.ID); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	return book, author
+}
+
+func findBook(books []*library.Book, id int64) *library.Book {
+	for _, book := range books {
+		if book.ID == id {
+			return book
+		}
+	}
+	return nil
+}
+
+func deleteRow(ctx context.Context, db *sqlx.DB, table string, id int64) error {
+	q := fmt.Sprintf(`DELETE FROM %s WHERE id = $1;`, table)
 	if _, err := db.ExecContext(ctx, q, id); err != nil {
-		return fmt.Errorf("delete book: %w", err)
+		return fmt.Errorf("delete %s: %w", table, err)
 	}
 	return nil
 }